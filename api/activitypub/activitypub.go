@@ -0,0 +1,648 @@
+// Package activitypub exposes local users as ActivityStreams actors,
+// handles the Follow/Undo{Follow} handshake with federated servers, and
+// federates posts as Create{Note}/Like/Delete activities delivered
+// through the Deliverer worker (see federation.go).
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"social-media-app/api/models"
+	"social-media-app/api/pagination"
+	"social-media-app/config"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const actorCacheTTL = 1 * time.Hour
+
+// PublicKey is the JSON-LD shape ActivityPub expects for an actor's key.
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+// Actor is a minimal ActivityStreams Person document.
+type Actor struct {
+	Context           []string  `json:"@context"`
+	ID                string    `json:"id"`
+	Type              string    `json:"type"`
+	PreferredUsername string    `json:"preferredUsername"`
+	Name              string    `json:"name"`
+	Inbox             string    `json:"inbox"`
+	Outbox            string    `json:"outbox"`
+	Followers         string    `json:"followers"`
+	Following         string    `json:"following"`
+	SharedInbox       string    `json:"-"`
+	PublicKey         PublicKey `json:"publicKey"`
+}
+
+// Activity is a generic envelope covering the activity types this handler
+// understands (Follow, Accept, Undo, Create, Like, Announce).
+type Activity struct {
+	Context interface{}     `json:"@context,omitempty"`
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Actor   string          `json:"actor"`
+	Object  json.RawMessage `json:"object,omitempty"`
+	To      []string        `json:"to,omitempty"`
+}
+
+// commentCreator is satisfied by *comment.CommentHandler. It's declared
+// here rather than imported directly because api/comment imports
+// api/auth, which imports api/activitypub - a direct import would create
+// a cycle. main.go wires the concrete handler in via SetCommentCreator
+// once both packages are set up.
+type commentCreator interface {
+	CreateRemoteComment(postID, localUserID, remoteActorURI, text string, parentID *string) (models.Comment, error)
+}
+
+type Handler struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	cfg         *config.Config
+	comments    commentCreator
+}
+
+func NewHandler(db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *Handler {
+	return &Handler{db: db, redisClient: redisClient, cfg: cfg}
+}
+
+// SetCommentCreator wires the comment package's handler in after both
+// packages have been set up, so federated replies persist through the
+// same path (and the same notification/digest fan-out) as local ones.
+func (h *Handler) SetCommentCreator(creator commentCreator) {
+	h.comments = creator
+}
+
+func (h *Handler) actorURI(username string) string {
+	return fmt.Sprintf("%s/users/%s", h.cfg.BaseURL, username)
+}
+
+func (h *Handler) actorFor(user *models.User) Actor {
+	uri := h.actorURI(user.Username)
+	return Actor{
+		Context:           []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"},
+		ID:                uri,
+		Type:              "Person",
+		PreferredUsername: user.Username,
+		Name:              user.Firstname + " " + user.Lastname,
+		Inbox:             uri + "/inbox",
+		Outbox:            uri + "/outbox",
+		Followers:         uri + "/followers",
+		Following:         uri + "/following",
+		PublicKey: PublicKey{
+			ID:           uri + "#main-key",
+			Owner:        uri,
+			PublicKeyPEM: user.PublicKey,
+		},
+	}
+}
+
+// GetActor serves /users/:username as an application/activity+json
+// Person document. The Accept header is not consulted; every client gets
+// the same ActivityPub representation.
+func (h *Handler) GetActor(c *fiber.Ctx) error {
+	username := c.Params("username")
+	var user models.User
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	c.Set("Content-Type", "application/activity+json")
+	return c.JSON(h.actorFor(&user))
+}
+
+// WebFinger resolves acct:username@host to the actor document location.
+func (h *Handler) WebFinger(c *fiber.Ctx) error {
+	resource := c.Query("resource")
+	if !strings.HasPrefix(resource, "acct:") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid resource"})
+	}
+	username := strings.SplitN(strings.TrimPrefix(resource, "acct:"), "@", 2)[0]
+
+	var user models.User
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	c.Set("Content-Type", "application/jrd+json")
+	return c.JSON(fiber.Map{
+		"subject": resource,
+		"links": []fiber.Map{
+			{
+				"rel":  "self",
+				"type": "application/activity+json",
+				"href": h.actorURI(user.Username),
+			},
+		},
+	})
+}
+
+// Outbox returns a cursor-paginated OrderedCollection of Create{Note}
+// activities built from the user's posts, newest first.
+func (h *Handler) Outbox(c *fiber.Ctx) error {
+	username := c.Params("username")
+	var user models.User
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	limit := pagination.ParseLimit(c.Query("limit"))
+	cursor, err := pagination.Decode(c.Query("cursor"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var posts []models.Post
+	q := pagination.Apply(h.db.Where("user_id = ?", user.ID), cursor, limit+1)
+	if err := q.Find(&posts).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var nextCursor string
+	if len(posts) > limit {
+		last := posts[limit-1]
+		nextCursor = pagination.Encode(last.CreatedAt, last.ID)
+		posts = posts[:limit]
+	}
+
+	items := make([]fiber.Map, len(posts))
+	for i, post := range posts {
+		items[i] = fiber.Map{
+			"id":     h.actorURI(username) + "/posts/" + post.ID + "/activity",
+			"type":   "Create",
+			"actor":  h.actorURI(username),
+			"object": h.noteFor(&user, &post),
+		}
+	}
+
+	c.Set("Content-Type", "application/activity+json")
+	return c.JSON(fiber.Map{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           h.actorURI(username) + "/outbox",
+		"type":         "OrderedCollection",
+		"orderedItems": items,
+		"next":         nextCursor,
+	})
+}
+
+// Followers lists the actor URIs following this user, mixing local
+// followers (resolved to their actor URI) with federated ones.
+func (h *Handler) Followers(c *fiber.Ctx) error {
+	return h.collection(c, "followers")
+}
+
+// Following lists the actor URIs this user follows.
+func (h *Handler) Following(c *fiber.Ctx) error {
+	return h.collection(c, "following")
+}
+
+func (h *Handler) collection(c *fiber.Ctx, field string) error {
+	username := c.Params("username")
+	var user models.User
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	ids := user.Followers
+	if field == "following" {
+		ids = user.Following
+	}
+
+	items := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if strings.Contains(id, "://") {
+			items = append(items, id)
+			continue
+		}
+		var local models.User
+		if err := h.db.Where("id = ?", id).First(&local).Error; err == nil {
+			items = append(items, h.actorURI(local.Username))
+		}
+	}
+
+	c.Set("Content-Type", "application/activity+json")
+	return c.JSON(fiber.Map{
+		"@context":     "https://www.w3.org/ns/activitystreams",
+		"id":           h.actorURI(username) + "/" + field,
+		"type":         "OrderedCollection",
+		"totalItems":   len(items),
+		"orderedItems": items,
+	})
+}
+
+// Inbox receives Follow/Accept/Undo and Create/Like/Announce activities
+// from remote servers.
+func (h *Handler) Inbox(c *fiber.Ctx) error {
+	username := c.Params("username")
+	var user models.User
+	if err := h.db.Where("username = ?", username).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	var activity Activity
+	if err := c.BodyParser(&activity); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid activity"})
+	}
+
+	// resolveActor has to run before verifySignature below: the public
+	// key it fetches is what the signature is checked against, so there's
+	// no way to verify first for an actor we haven't seen before. That
+	// makes this fetch reachable by an unauthenticated POST, so
+	// resolveActor itself (and every other outbound federation request)
+	// is SSRF-hardened via validateFederationURL/federationClient rather
+	// than trusted as "safe because it's gated behind auth".
+	remoteActor, err := h.resolveActor(activity.Actor)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Failed to resolve actor: " + err.Error()})
+	}
+
+	httpReq, err := http.NewRequest(c.Method(), h.actorURI(username)+"/inbox", bytes.NewReader(c.Body()))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	for key, values := range c.GetReqHeaders() {
+		for _, v := range values {
+			httpReq.Header.Add(key, v)
+		}
+	}
+	if err := verifySignature(httpReq, remoteActor.PublicKey, c.Body()); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid signature"})
+	}
+
+	switch activity.Type {
+	case "Follow":
+		h.appendUnique(&user.Followers, activity.Actor)
+		if err := h.db.Save(&user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+		go h.deliver(&user, Activity{
+			Context: "https://www.w3.org/ns/activitystreams",
+			Type:    "Accept",
+			Actor:   h.actorURI(username),
+			Object:  mustMarshal(activity),
+		}, remoteActor.Inbox)
+
+	case "Undo":
+		h.removeValue(&user.Followers, activity.Actor)
+		if err := h.db.Save(&user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+
+	case "Accept":
+		h.appendUnique(&user.Following, activity.Actor)
+		if err := h.db.Save(&user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+
+	case "Create":
+		h.handleCreate(activity, remoteActor)
+
+	case "Like":
+		h.handleLike(activity)
+
+	case "Announce":
+		h.handleAnnounce(activity)
+	}
+
+	return c.SendStatus(fiber.StatusAccepted)
+}
+
+// federationClient is used for every outbound request to a remote
+// server's actor document or inbox, both of which come from
+// attacker-influenceable input (an unauthenticated Inbox POST's "actor"
+// field, or a Follow target). It never follows redirects (a validated
+// URL could otherwise 3xx to an internal one) and dials through
+// dialValidated, which pins the connection to the exact IP it checked
+// instead of trusting a second, later DNS resolution — closing the
+// rebinding gap a naive re-resolving dial would leave open.
+var federationClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DialContext: dialValidated,
+	},
+}
+
+// validateFederationURL rejects actor/inbox URIs that could be used to
+// make this server issue requests against itself or internal
+// infrastructure (SSRF): only https URLs with a host are allowed. It does
+// not resolve the host; the check that every resolved address is public
+// and routable happens at connect time in dialValidated.
+func validateFederationURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid federation url")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("federation url must use https")
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("invalid federation url")
+	}
+	return nil
+}
+
+// dialValidated resolves addr's host itself and rejects it unless every
+// resolved IP is public, then dials that exact IP. See federationClient.
+func dialValidated(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("could not resolve federation host")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return nil, fmt.Errorf("federation url resolves to a disallowed address")
+		}
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+}
+
+// isPublicIP reports whether ip is a globally routable address, i.e.
+// not loopback, private, link-local, multicast, or unspecified.
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+// resolveActor fetches (and caches) a remote actor document, persisting it
+// as a models.RemoteUser so local follow lists can reference it.
+func (h *Handler) resolveActor(actorURI string) (*models.RemoteUser, error) {
+	ctx := context.Background()
+	cacheKey := "actor:" + actorURI
+
+	var remote models.RemoteUser
+	if err := h.db.Where("actor_id = ?", actorURI).First(&remote).Error; err == nil {
+		return &remote, nil
+	}
+
+	cached, err := h.redisClient.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var actor Actor
+		if err := json.Unmarshal([]byte(cached), &actor); err == nil {
+			remote = models.RemoteUser{
+				ActorID:     actor.ID,
+				Inbox:       actor.Inbox,
+				SharedInbox: actor.SharedInbox,
+				PublicKey:   actor.PublicKey.PublicKeyPEM,
+			}
+			h.db.Create(&remote)
+			return &remote, nil
+		}
+	}
+
+	if err := validateFederationURL(actorURI); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, actorURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/activity+json")
+	resp, err := federationClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var actor Actor
+	if err := json.NewDecoder(resp.Body).Decode(&actor); err != nil {
+		return nil, err
+	}
+
+	actorJSON, _ := json.Marshal(actor)
+	h.redisClient.Set(ctx, cacheKey, actorJSON, actorCacheTTL)
+
+	remote = models.RemoteUser{
+		ActorID:   actor.ID,
+		Inbox:     actor.Inbox,
+		PublicKey: actor.PublicKey.PublicKeyPEM,
+	}
+	if err := h.db.Create(&remote).Error; err != nil {
+		return nil, err
+	}
+	return &remote, nil
+}
+
+// deliver signs activity with the sending user's private key and POSTs it
+// to the remote inbox.
+func (h *Handler) deliver(sender *models.User, activity Activity, inbox string) {
+	if err := validateFederationURL(inbox); err != nil {
+		log.Printf("activitypub: refusing to deliver to %s: %v", inbox, err)
+		return
+	}
+
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+
+	keyID := h.actorURI(sender.Username) + "#main-key"
+	if err := signRequest(req, keyID, sender.PrivateKey, body); err != nil {
+		return
+	}
+
+	resp, err := federationClient.Do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+}
+
+// DeliverFollow dispatches a Follow activity to a remote actor's inbox on
+// behalf of the local user.
+func (h *Handler) DeliverFollow(sender *models.User, targetActorURI string) error {
+	remote, err := h.resolveActor(targetActorURI)
+	if err != nil {
+		return err
+	}
+	h.deliver(sender, Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Follow",
+		Actor:   h.actorURI(sender.Username),
+		Object:  mustMarshal(targetActorURI),
+	}, remote.Inbox)
+	return nil
+}
+
+// DeliverUndoFollow dispatches Undo{Follow} to a remote actor's inbox.
+func (h *Handler) DeliverUndoFollow(sender *models.User, targetActorURI string) error {
+	remote, err := h.resolveActor(targetActorURI)
+	if err != nil {
+		return err
+	}
+	h.deliver(sender, Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Undo",
+		Actor:   h.actorURI(sender.Username),
+		Object: mustMarshal(Activity{
+			Type:   "Follow",
+			Actor:  h.actorURI(sender.Username),
+			Object: mustMarshal(targetActorURI),
+		}),
+	}, remote.Inbox)
+	return nil
+}
+
+// noteFor builds the ActivityStreams Note object for a post.
+func (h *Handler) noteFor(sender *models.User, post *models.Post) fiber.Map {
+	uri := h.actorURI(sender.Username)
+	return fiber.Map{
+		"id":           uri + "/posts/" + post.ID,
+		"type":         "Note",
+		"attributedTo": uri,
+		"content":      post.Desc,
+		"published":    post.CreatedAt.Format(time.RFC3339),
+		"to":           []string{"https://www.w3.org/ns/activitystreams#Public"},
+	}
+}
+
+// postIDFromURI extracts the trailing post ID from a Note/Like object
+// URI of the form ".../posts/<id>".
+func postIDFromURI(uri string) string {
+	const marker = "/posts/"
+	idx := strings.LastIndex(uri, marker)
+	if idx == -1 {
+		return ""
+	}
+	return uri[idx+len(marker):]
+}
+
+// handleCreate records a federated reply as a local Comment so it shows
+// up alongside local replies. The cached RemoteUser row's own id (a
+// local uuid) stands in for UserID, since that column is uuid-typed and
+// can't hold the remote actor's URI directly.
+func (h *Handler) handleCreate(activity Activity, remoteActor *models.RemoteUser) {
+	var note struct {
+		Content   string `json:"content"`
+		InReplyTo string `json:"inReplyTo"`
+	}
+	if err := json.Unmarshal(activity.Object, &note); err != nil {
+		return
+	}
+	postID := postIDFromURI(note.InReplyTo)
+	if postID == "" {
+		return
+	}
+	if h.comments == nil {
+		log.Printf("activitypub: no comment creator wired, dropping federated reply to post %s", postID)
+		return
+	}
+	if _, err := h.comments.CreateRemoteComment(postID, remoteActor.ID, remoteActor.ActorID, note.Content, nil); err != nil {
+		log.Printf("activitypub: failed to save federated reply: %v", err)
+	}
+}
+
+// handleLike records a federated Like by tagging the target post's
+// "like" reaction list with the remote actor's URI.
+func (h *Handler) handleLike(activity Activity) {
+	h.tagReaction(activity, "like")
+}
+
+// handleAnnounce records a federated boost the same way a Like is
+// recorded, under the "share" reaction key.
+func (h *Handler) handleAnnounce(activity Activity) {
+	h.tagReaction(activity, "share")
+}
+
+func (h *Handler) tagReaction(activity Activity, reaction string) {
+	var objectURI string
+	if err := json.Unmarshal(activity.Object, &objectURI); err != nil {
+		return
+	}
+	postID := postIDFromURI(objectURI)
+	if postID == "" {
+		return
+	}
+	var post models.Post
+	if err := h.db.Where("id = ?", postID).First(&post).Error; err != nil {
+		return
+	}
+	if post.Reactions == nil {
+		post.Reactions = make(map[string][]string)
+	}
+	post.Reactions[reaction] = appendUniqueString(post.Reactions[reaction], activity.Actor)
+	if err := h.db.Save(&post).Error; err != nil {
+		log.Printf("activitypub: failed to save federated %s: %v", reaction, err)
+	}
+}
+
+func appendUniqueString(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
+
+func (h *Handler) appendUnique(list *models.StringArray, value string) {
+	for _, v := range *list {
+		if v == value {
+			return
+		}
+	}
+	*list = append(*list, value)
+}
+
+func (h *Handler) removeValue(list *models.StringArray, value string) {
+	for i, v := range *list {
+		if v == value {
+			*list = append((*list)[:i], (*list)[i+1:]...)
+			return
+		}
+	}
+}
+
+func mustMarshal(v interface{}) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// Setup mounts the federation endpoints on the root app (not under /api,
+// since .well-known paths and actor URIs must be at the domain root).
+func Setup(app fiber.Router, db *gorm.DB, redisClient *redis.Client, cfg *config.Config) *Handler {
+	handler := NewHandler(db, redisClient, cfg)
+
+	app.Get("/.well-known/webfinger", handler.WebFinger)
+	app.Get("/users/:username", handler.GetActor)
+	app.Post("/users/:username/inbox", handler.Inbox)
+	app.Get("/users/:username/outbox", handler.Outbox)
+	app.Get("/users/:username/followers", handler.Followers)
+	app.Get("/users/:username/following", handler.Following)
+
+	go NewDeliverer(handler).Start(context.Background())
+
+	return handler
+}
@@ -0,0 +1,243 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"social-media-app/api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// deliveryQueueKey is the Redis list PostHandler enqueues onto and
+// Deliverer.Start pops from. deliveryRetryKey is a sorted set of jobs
+// that failed and are waiting out a backoff, scored by the Unix
+// timestamp at which they're next due.
+const (
+	deliveryQueueKey = "activitypub:deliveries"
+	deliveryRetryKey = "activitypub:deliveries:retry"
+
+	maxDeliveryAttempts = 5
+	retryBaseDelay      = 30 * time.Second
+)
+
+// deliveryJob is the payload queued on Redis for a Deliverer to process.
+// It carries the sender's id rather than their private key so the queue
+// itself never holds key material. Attempt tracks how many times
+// delivery has been tried, so Start can give up after
+// maxDeliveryAttempts instead of retrying a dead inbox forever.
+type deliveryJob struct {
+	SenderID string          `json:"sender_id"`
+	Inbox    string          `json:"inbox"`
+	Activity json.RawMessage `json:"activity"`
+	Attempt  int             `json:"attempt"`
+}
+
+// Deliverer pops queued post deliveries off Redis and signs/POSTs them to
+// the target inbox on the sender's behalf. PostHandler enqueues through
+// Handler.enqueueDelivery instead of delivering inline so CreatePost,
+// LikePost and DeletePost don't block the request on a slow or
+// unreachable remote server.
+type Deliverer struct {
+	h *Handler
+}
+
+func NewDeliverer(h *Handler) *Deliverer {
+	return &Deliverer{h: h}
+}
+
+// Start blocks popping jobs off the Redis queue until ctx is cancelled,
+// interleaving each pop with a check for retries whose backoff has
+// elapsed.
+func (d *Deliverer) Start(ctx context.Context) {
+	for {
+		d.deliverDueRetries(ctx)
+
+		result, err := d.h.redisClient.BLPop(ctx, 5*time.Second, deliveryQueueKey).Result()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if len(result) < 2 {
+			continue
+		}
+		var job deliveryJob
+		if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
+			log.Printf("activitypub: dropping malformed delivery job: %v", err)
+			continue
+		}
+		d.attemptDelivery(ctx, job)
+	}
+}
+
+// deliverDueRetries re-attempts every job in deliveryRetryKey whose
+// backoff has elapsed.
+func (d *Deliverer) deliverDueRetries(ctx context.Context) {
+	due, err := d.h.redisClient.ZRangeByScore(ctx, deliveryRetryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return
+	}
+	for _, raw := range due {
+		d.h.redisClient.ZRem(ctx, deliveryRetryKey, raw)
+		var job deliveryJob
+		if err := json.Unmarshal([]byte(raw), &job); err != nil {
+			continue
+		}
+		d.attemptDelivery(ctx, job)
+	}
+}
+
+// attemptDelivery tries job once; on failure it's rescheduled onto
+// deliveryRetryKey with an exponential backoff until maxDeliveryAttempts
+// is reached, at which point it's dropped.
+func (d *Deliverer) attemptDelivery(ctx context.Context, job deliveryJob) {
+	job.Attempt++
+	if err := d.deliverJob(job); err == nil {
+		return
+	} else if job.Attempt >= maxDeliveryAttempts {
+		log.Printf("activitypub: giving up on delivery to %s after %d attempts: %v", job.Inbox, job.Attempt, err)
+		return
+	}
+
+	delay := retryBaseDelay * time.Duration(1<<uint(job.Attempt-1))
+	payload, marshalErr := json.Marshal(job)
+	if marshalErr != nil {
+		return
+	}
+	d.h.redisClient.ZAdd(ctx, deliveryRetryKey, redis.Z{
+		Score:  float64(time.Now().Add(delay).Unix()),
+		Member: payload,
+	})
+}
+
+func (d *Deliverer) deliverJob(job deliveryJob) error {
+	// Re-check on every attempt, not just at enqueue time: the IP itself
+	// is validated per-connection by dialValidated.
+	if err := validateFederationURL(job.Inbox); err != nil {
+		return err
+	}
+
+	var sender models.User
+	if err := d.h.db.Where("id = ?", job.SenderID).First(&sender).Error; err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, job.Inbox, bytes.NewReader(job.Activity))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+
+	keyID := d.h.actorURI(sender.Username) + "#main-key"
+	if err := signRequest(req, keyID, sender.PrivateKey, job.Activity); err != nil {
+		return err
+	}
+
+	resp, err := federationClient.Do(req)
+	if err != nil {
+		log.Printf("activitypub: delivery to %s failed: %v", job.Inbox, err)
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("delivery to %s returned status %d", job.Inbox, resp.StatusCode)
+	}
+	return nil
+}
+
+// enqueueDelivery queues activity for asynchronous delivery to inbox on
+// sender's behalf.
+func (h *Handler) enqueueDelivery(sender *models.User, inbox string, activity Activity) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(deliveryJob{SenderID: sender.ID, Inbox: inbox, Activity: body})
+	if err != nil {
+		return err
+	}
+	return h.redisClient.RPush(context.Background(), deliveryQueueKey, payload).Err()
+}
+
+// remoteFollowerInboxes resolves each federated follower URI in
+// followers to its (shared) inbox, skipping ones that fail to resolve
+// and de-duplicating so a shared inbox isn't delivered to twice.
+func (h *Handler) remoteFollowerInboxes(followers models.StringArray) []string {
+	seen := make(map[string]bool)
+	var inboxes []string
+	for _, uri := range followers {
+		if !strings.Contains(uri, "://") {
+			continue // local follower, not federated
+		}
+		remote, err := h.resolveActor(uri)
+		if err != nil {
+			continue
+		}
+		inbox := remote.SharedInbox
+		if inbox == "" {
+			inbox = remote.Inbox
+		}
+		if inbox == "" || seen[inbox] {
+			continue
+		}
+		seen[inbox] = true
+		inboxes = append(inboxes, inbox)
+	}
+	return inboxes
+}
+
+func (h *Handler) fanOut(sender *models.User, activity Activity) {
+	for _, inbox := range h.remoteFollowerInboxes(sender.Followers) {
+		if err := h.enqueueDelivery(sender, inbox, activity); err != nil {
+			log.Printf("activitypub: failed to enqueue delivery to %s: %v", inbox, err)
+		}
+	}
+}
+
+// DeliverCreateNote fans a Create{Note} activity for post out to every
+// federated follower of sender.
+func (h *Handler) DeliverCreateNote(sender *models.User, post *models.Post) {
+	h.fanOut(sender, Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Create",
+		Actor:   h.actorURI(sender.Username),
+		Object:  mustMarshal(h.noteFor(sender, post)),
+	})
+}
+
+// DeliverLike fans out a Like activity for post to sender's federated
+// followers.
+func (h *Handler) DeliverLike(sender *models.User, post *models.Post) {
+	h.fanOut(sender, Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Like",
+		Actor:   h.actorURI(sender.Username),
+		Object:  mustMarshal(h.actorURI(sender.Username) + "/posts/" + post.ID),
+	})
+}
+
+// DeliverDelete fans out a Delete{Tombstone} activity for post to
+// sender's federated followers.
+func (h *Handler) DeliverDelete(sender *models.User, post *models.Post) {
+	h.fanOut(sender, Activity{
+		Context: "https://www.w3.org/ns/activitystreams",
+		Type:    "Delete",
+		Actor:   h.actorURI(sender.Username),
+		Object: mustMarshal(fiber.Map{
+			"id":   h.actorURI(sender.Username) + "/posts/" + post.ID,
+			"type": "Tombstone",
+		}),
+	})
+}
@@ -5,11 +5,11 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"social-media-app/api/activitypub"
 	"social-media-app/api/models"
 	"social-media-app/config"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -101,6 +101,12 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to parse Supabase response"})
 	}
 
+	// Generate an RSA keypair so this user can act as an ActivityPub actor.
+	publicKey, privateKey, err := activitypub.GenerateKeyPair()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate actor keypair"})
+	}
+
 	user := models.User{
 		ID:             supabaseUser.ID,
 		Username:       req.Username,
@@ -116,17 +122,15 @@ func (h *AuthHandler) Register(c *fiber.Ctx) error {
 		WorksAt:        req.WorksAt,
 		Relationship:   req.Relationship,
 		Country:        req.Country,
+		PublicKey:      publicKey,
+		PrivateKey:     privateKey,
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save user to database"})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": user.Username,
-		"id":       user.ID,
-	})
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	tokenString, err := h.issueTokenPair(c, &user)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate token"})
 	}
@@ -176,16 +180,14 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": user.Username,
-		"id":       user.ID,
-	})
-	tokenString, err := token.SignedString([]byte(h.cfg.JWTSecret))
+	// Route through the same MFA negotiation as /auth/challenge so an
+	// account with enrolled factors can't bypass them via this endpoint.
+	result, err := h.beginChallenge(&user, c)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate token"})
 	}
 
-	return c.JSON(fiber.Map{"user": user, "token": tokenString})
+	return c.JSON(result)
 }
 
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client, cfg *config.Config) {
@@ -193,4 +195,16 @@ func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client, cfg *config
 	auth := api.Group("/auth")
 	auth.Post("/register", handler.Register)
 	auth.Post("/login", handler.Login)
+
+	// Refresh rotates the httpOnly refresh-token cookie for a new access
+	// token; Logout revokes both it and the presented access token's jti.
+	auth.Post("/refresh", handler.Refresh)
+	auth.Post("/logout", JWTMiddleware(cfg, redisClient), handler.Logout)
+
+	// Multi-factor login negotiation: /challenge starts it, /verify
+	// satisfies one factor at a time until RemainingSteps reaches zero.
+	auth.Post("/challenge", handler.Challenge)
+	auth.Post("/challenge/:id/verify", handler.VerifyChallenge)
+	auth.Post("/factors/totp/enroll", JWTMiddleware(cfg, redisClient), handler.EnrollTOTP)
+	auth.Post("/factors/backup-codes/enroll", JWTMiddleware(cfg, redisClient), handler.EnrollBackupCodes)
 }
@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/smtp"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pquerna/otp/totp"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	challengeTTL         = 10 * time.Minute
+	challengeMaxAttempts = 5
+)
+
+type ChallengeRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+}
+
+type VerifyFactorRequest struct {
+	FactorID string `json:"factor_id" validate:"required"`
+	Secret   string `json:"secret" validate:"required"`
+}
+
+// Challenge starts the login negotiation: it checks the password factor and
+// returns a challenge_id plus the IDs of any remaining factors the user
+// must satisfy before a JWT is issued.
+func (h *AuthHandler) Challenge(c *fiber.Ctx) error {
+	var req ChallengeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var user models.User
+	if err := h.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Incorrect password"})
+	}
+
+	result, err := h.beginChallenge(&user, c)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(result)
+}
+
+// beginChallenge starts MFA for a user who's already presented a valid
+// password: if they have no other factors enrolled it issues tokens right
+// away, otherwise it opens a pending AuthChallenge and returns its id plus
+// the factor IDs the caller must satisfy next. Both Challenge and the
+// legacy Login endpoint funnel through here so neither can skip MFA.
+func (h *AuthHandler) beginChallenge(user *models.User, c *fiber.Ctx) (fiber.Map, error) {
+	var factors []models.AuthFactor
+	if err := h.db.Where("user_id = ? AND type != ?", user.ID, "password").Find(&factors).Error; err != nil {
+		return nil, err
+	}
+
+	challenge := models.AuthChallenge{
+		UserID:         user.ID,
+		IP:             c.IP(),
+		UserAgent:      c.Get("User-Agent"),
+		RemainingSteps: countMandatorySteps(factors),
+		State:          "pending",
+		ExpiresAt:      time.Now().Add(challengeTTL),
+	}
+	if err := h.db.Create(&challenge).Error; err != nil {
+		return nil, err
+	}
+
+	h.recordAuthEvent(user.ID, c, "", "success")
+
+	if challenge.RemainingSteps == 0 {
+		token, err := h.issueTokenPair(c, user)
+		if err != nil {
+			return nil, err
+		}
+		challenge.State = "completed"
+		h.db.Save(&challenge)
+		return fiber.Map{"challenge_id": challenge.ID, "token": token}, nil
+	}
+
+	factorIDs := make([]string, len(factors))
+	for i, f := range factors {
+		factorIDs[i] = f.ID
+	}
+	return fiber.Map{"challenge_id": challenge.ID, "factor_ids": factorIDs}, nil
+}
+
+// countMandatorySteps returns how many challenge steps a user's enrolled
+// factors require. Each distinct OTP-like type (totp, email_otp, ...) is
+// its own mandatory step; backup_code factors don't add a step of their
+// own since they're an alternate way to satisfy whichever OTP-like step
+// the user has, but if backup codes are the only factor enrolled they
+// stand in as the one mandatory step.
+func countMandatorySteps(factors []models.AuthFactor) int {
+	stepTypes := map[string]bool{}
+	hasBackupCode := false
+	for _, f := range factors {
+		if f.Type == "backup_code" {
+			hasBackupCode = true
+			continue
+		}
+		stepTypes[f.Type] = true
+	}
+	if len(stepTypes) == 0 && hasBackupCode {
+		return 1
+	}
+	return len(stepTypes)
+}
+
+// VerifyChallenge validates one factor against a pending challenge. Once
+// RemainingSteps reaches zero the JWT is issued.
+func (h *AuthHandler) VerifyChallenge(c *fiber.Ctx) error {
+	challengeID := c.Params("id")
+
+	ctx := context.Background()
+	attemptsKey := "challenge:" + challengeID + ":attempts"
+	attempts, _ := h.redisClient.Incr(ctx, attemptsKey).Result()
+	h.redisClient.Expire(ctx, attemptsKey, challengeTTL)
+	if attempts > challengeMaxAttempts {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"message": "Too many attempts, challenge locked"})
+	}
+
+	var req VerifyFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var challenge models.AuthChallenge
+	if err := h.db.Where("id = ?", challengeID).First(&challenge).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Challenge not found"})
+	}
+	if challenge.State != "pending" || time.Now().After(challenge.ExpiresAt) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Challenge expired or already completed"})
+	}
+	for _, used := range challenge.BlacklistedFactors {
+		if used == req.FactorID {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Factor already used for this challenge"})
+		}
+	}
+
+	var factor models.AuthFactor
+	if err := h.db.Where("id = ? AND user_id = ?", req.FactorID, challenge.UserID).First(&factor).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Factor not found"})
+	}
+
+	if err := h.verifyFactor(&factor, req.Secret); err != nil {
+		h.recordAuthEvent(challenge.UserID, c, factor.ID, "failure")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+	h.recordAuthEvent(challenge.UserID, c, factor.ID, "success")
+
+	if factor.Type == "backup_code" {
+		if err := h.db.Delete(&factor).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	challenge.BlacklistedFactors = append(challenge.BlacklistedFactors, factor.ID)
+	challenge.RemainingSteps--
+
+	if challenge.RemainingSteps <= 0 {
+		var user models.User
+		if err := h.db.Where("id = ?", challenge.UserID).First(&user).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+		token, err := h.issueTokenPair(c, &user)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate token"})
+		}
+		challenge.State = "completed"
+		if err := h.db.Save(&challenge).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+		return c.JSON(fiber.Map{"user": user, "token": token})
+	}
+
+	if err := h.db.Save(&challenge).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"remaining_steps": challenge.RemainingSteps})
+}
+
+func (h *AuthHandler) verifyFactor(factor *models.AuthFactor, secret string) error {
+	switch factor.Type {
+	case "totp":
+		if !totp.Validate(secret, factor.Secret) {
+			return fmt.Errorf("invalid TOTP code")
+		}
+	case "email_otp", "backup_code":
+		if err := bcrypt.CompareHashAndPassword([]byte(factor.Secret), []byte(secret)); err != nil {
+			return fmt.Errorf("invalid code")
+		}
+	default:
+		return fmt.Errorf("unsupported factor type")
+	}
+	return nil
+}
+
+// EnrollTOTP creates a TOTP factor for the authenticated user and returns
+// the provisioning URI so it can be rendered as a QR code.
+func (h *AuthHandler) EnrollTOTP(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      "social-media-app",
+		AccountName: user.Username,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate TOTP secret"})
+	}
+
+	factor := models.AuthFactor{
+		UserID: userID,
+		Type:   "totp",
+		Secret: key.Secret(),
+	}
+	if err := h.db.Create(&factor).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"factor_id": factor.ID, "provisioning_uri": key.URL()})
+}
+
+// backupCodeCount is how many single-use backup codes EnrollBackupCodes
+// generates per call.
+const backupCodeCount = 10
+
+// EnrollBackupCodes generates a fresh set of single-use backup codes for
+// the authenticated user, each stored as its own "backup_code" factor so
+// VerifyChallenge can consume them one at a time. The plaintext codes are
+// only ever returned here; only their bcrypt hashes are persisted.
+func (h *AuthHandler) EnrollBackupCodes(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	codes := make([]string, backupCodeCount)
+	factors := make([]models.AuthFactor, backupCodeCount)
+	for i := range codes {
+		code, err := generateBackupCode()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate backup codes"})
+		}
+		hashed, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate backup codes"})
+		}
+		codes[i] = code
+		factors[i] = models.AuthFactor{UserID: userID, Type: "backup_code", Secret: string(hashed)}
+	}
+
+	if err := h.db.Create(&factors).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"codes": codes})
+}
+
+// generateBackupCode returns a random 10-character hex code.
+func generateBackupCode() (string, error) {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// sendEmailOTP mails a 6-digit code to the user and stores its bcrypt hash
+// as the factor secret so a login challenge step can later verify it.
+func (h *AuthHandler) sendEmailOTP(user *models.User, code string) error {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	factor := models.AuthFactor{
+		UserID: user.ID,
+		Type:   "email_otp",
+		Secret: string(hashed),
+	}
+	if err := h.db.Create(&factor).Error; err != nil {
+		return err
+	}
+
+	if h.cfg.SMTPHost == "" {
+		return nil // SMTP not configured; skip actual delivery (e.g. local dev)
+	}
+
+	addr := h.cfg.SMTPHost + ":" + h.cfg.SMTPPort
+	auth := smtp.PlainAuth("", h.cfg.SMTPUsername, h.cfg.SMTPPassword, h.cfg.SMTPHost)
+	msg := []byte("Subject: Your verification code\r\n\r\nYour code is: " + code + "\r\n")
+	return smtp.SendMail(addr, auth, h.cfg.SMTPFrom, []string{user.Email}, msg)
+}
+
+func (h *AuthHandler) recordAuthEvent(userID string, c *fiber.Ctx, factorID string, outcome string) {
+	event := models.AuthEvent{
+		UserID:    userID,
+		IP:        c.IP(),
+		UserAgent: c.Get("User-Agent"),
+		FactorID:  factorID,
+		Outcome:   outcome,
+	}
+	h.db.Create(&event)
+}
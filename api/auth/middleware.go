@@ -1,13 +1,19 @@
 package auth
 
 import (
+	"fmt"
+	"social-media-app/api/models"
 	"social-media-app/config"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
-func JWTMiddleware(cfg *config.Config) fiber.Handler {
+func JWTMiddleware(cfg *config.Config, redisClient *redis.Client) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tokenString := c.Get("Authorization")
 		if tokenString == "" {
@@ -18,21 +24,112 @@ func JWTMiddleware(cfg *config.Config) fiber.Handler {
 			tokenString = tokenString[7:]
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecret), nil
-		})
+		userID, username, jti, err := ParseUserFromToken(tokenString, cfg, redisClient)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		c.Locals("user_id", userID)
+		c.Locals("username", username)
+		c.Locals("jti", jti)
+		return c.Next()
+	}
+}
 
-		if err != nil || !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid token"})
+// WebSocketJWTMiddleware authenticates a WebSocket upgrade the same way
+// JWTMiddleware authenticates an ordinary request, except the token
+// travels as a "token" query param or, for clients that prefer not to put
+// it in the URL, the Sec-WebSocket-Protocol header, since browsers can't
+// set an Authorization header on the handshake. It must run directly
+// before the websocket.New handler so a rejected upgrade never reaches it.
+func WebSocketJWTMiddleware(cfg *config.Config, redisClient *redis.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			tokenString = c.Get("Sec-WebSocket-Protocol")
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Invalid claims"})
+		userID, username, jti, err := ParseUserFromToken(tokenString, cfg, redisClient)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		c.Locals("user_id", userID)
+		c.Locals("username", username)
+		c.Locals("jti", jti)
+		return c.Next()
+	}
+}
+
+// RequireAdmin gates a route to users with IsAdmin set. It must run after
+// JWTMiddleware, which populates the user_id local it looks up.
+func RequireAdmin(db *gorm.DB) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, _ := c.Locals("user_id").(string)
+
+		var user models.User
+		if err := db.Where("id = ?", userID).First(&user).Error; err != nil || !user.IsAdmin {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Admin access required"})
 		}
 
-		c.Locals("user_id", claims["id"])
-		c.Locals("username", claims["username"])
 		return c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// ParseUserFromToken validates a JWT issued by IssueAccessToken and
+// returns the subject's id, username, and jti claims. It is shared by
+// JWTMiddleware and the ws package, which authenticates its upgrade
+// request the same way but can't rely on an Authorization header
+// (browsers don't let JS set one on a WebSocket handshake).
+//
+// Claims validation is disabled on the parser so exp/nbf are checked
+// explicitly below rather than folded into token.Valid, and jti is
+// checked against redisClient's revoked-token keyspace so a logged-out
+// or password-changed token is rejected even while still unexpired.
+// redisClient may be nil (e.g. tests), which skips the revocation check.
+func ParseUserFromToken(tokenString string, cfg *config.Config, redisClient *redis.Client) (userID, username, jti string, err error) {
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return "", "", "", fmt.Errorf("invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", "", "", fmt.Errorf("invalid claims")
+	}
+
+	now := time.Now()
+	exp, expErr := claims.GetExpirationTime()
+	if expErr != nil || exp == nil || now.After(exp.Time) {
+		return "", "", "", fmt.Errorf("token expired")
+	}
+	if nbf, nbfErr := claims.GetNotBefore(); nbfErr == nil && nbf != nil && now.Before(nbf.Time) {
+		return "", "", "", fmt.Errorf("token not yet valid")
+	}
+
+	id, _ := claims["id"].(string)
+	name, _ := claims["username"].(string)
+	tokenJTI, _ := claims["jti"].(string)
+	if id == "" {
+		return "", "", "", fmt.Errorf("invalid claims")
+	}
+
+	if redisClient != nil {
+		revoked, err := isJTIRevoked(redisClient, tokenJTI)
+		if err != nil {
+			return "", "", "", fmt.Errorf("failed to check token revocation: %w", err)
+		}
+		if revoked {
+			return "", "", "", fmt.Errorf("token revoked")
+		}
+	}
+
+	return id, name, tokenJTI, nil
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// refreshCookieName is the httpOnly cookie carrying the opaque refresh
+// token. It's scoped to /api/auth so it isn't sent on every request.
+const refreshCookieName = "refresh_token"
+
+func setRefreshCookie(c *fiber.Ctx, token string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookieName,
+		Value:    token,
+		Path:     "/api/auth",
+		Expires:  time.Now().Add(RefreshTokenTTL),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+func clearRefreshCookie(c *fiber.Ctx) {
+	c.Cookie(&fiber.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		Path:     "/api/auth",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: "Lax",
+	})
+}
+
+// issueTokenPair mints an access token and a refresh token for user,
+// setting the refresh token as an httpOnly cookie and returning the
+// access token for the client to send as a Bearer header.
+func (h *AuthHandler) issueTokenPair(c *fiber.Ctx, user *models.User) (string, error) {
+	access, err := IssueAccessToken(user, h.cfg.JWTSecret)
+	if err != nil {
+		return "", err
+	}
+
+	refresh, err := issueRefreshToken(h.db, user.ID, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		return "", err
+	}
+
+	setRefreshCookie(c, refresh)
+	return access, nil
+}
+
+// Refresh rotates the refresh token cookie and returns a fresh access
+// token. Reuse of an already-rotated token revokes every refresh token
+// for that user (see rotateRefreshToken).
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	presented := c.Cookies(refreshCookieName)
+	if presented == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "Missing refresh token"})
+	}
+
+	newRefresh, userID, err := rotateRefreshToken(h.db, presented, c.IP(), c.Get("User-Agent"))
+	if err != nil {
+		clearRefreshCookie(c)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	access, err := IssueAccessToken(&user, h.cfg.JWTSecret)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate token"})
+	}
+
+	setRefreshCookie(c, newRefresh)
+	return c.JSON(fiber.Map{"token": access})
+}
+
+// Logout revokes the presented refresh token and, since it runs behind
+// JWTMiddleware, the jti of the access token used to call it, so neither
+// can be replayed after this returns.
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	if presented := c.Cookies(refreshCookieName); presented != "" {
+		if err := revokeRefreshToken(h.db, presented); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+	if jti, _ := c.Locals("jti").(string); jti != "" {
+		if err := RevokeJTI(h.redisClient, jti); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	clearRefreshCookie(c)
+	return c.JSON(fiber.Map{"message": "Logged out"})
+}
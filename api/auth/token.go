@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// AccessTokenTTL is how long a signed JWT is valid for.
+	AccessTokenTTL = 15 * time.Minute
+	// RefreshTokenTTL is how long an opaque refresh token is valid for.
+	RefreshTokenTTL = 30 * 24 * time.Hour
+
+	refreshTokenBytes = 32
+
+	// revokedJTIPrefix namespaces the Redis keys JWTMiddleware checks to
+	// reject an otherwise-valid access token early, e.g. after logout or
+	// a password change. Each key is set with a TTL of AccessTokenTTL,
+	// the longest a token can still be valid for, so every key expires
+	// no later than AccessTokenTTL after the token it blocks.
+	revokedJTIPrefix = "revoked:jti:"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// IssueAccessToken mints a short-lived JWT carrying id/username/jti plus
+// exp/nbf/iat, so JWTMiddleware can check both expiry and revocation.
+func IssueAccessToken(user *models.User, jwtSecret string) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":       user.ID,
+		"username": user.Username,
+		"jti":      uuid.New().String(),
+		"iat":      now.Unix(),
+		"nbf":      now.Unix(),
+		"exp":      now.Add(AccessTokenTTL).Unix(),
+	})
+	return token.SignedString([]byte(jwtSecret))
+}
+
+// RevokeJTI blocks the access token identified by jti from passing
+// JWTMiddleware again, until it would have expired anyway.
+func RevokeJTI(redisClient *redis.Client, jti string) error {
+	if jti == "" {
+		return nil
+	}
+	return redisClient.Set(context.Background(), revokedJTIPrefix+jti, "1", AccessTokenTTL).Err()
+}
+
+func isJTIRevoked(redisClient *redis.Client, jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	n, err := redisClient.Exists(context.Background(), revokedJTIPrefix+jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshTokenPlaintext() (string, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// issueRefreshToken generates an opaque token, stores its hash, and
+// returns the plaintext to hand back to the client (in an httpOnly
+// cookie).
+func issueRefreshToken(db *gorm.DB, userID, ip, userAgent string) (string, error) {
+	plain, err := newRefreshTokenPlaintext()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashRefreshToken(plain),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+		UserAgent: userAgent,
+		IP:        ip,
+	}
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// rotateRefreshToken redeems plainToken for a fresh one belonging to the
+// same user. Presenting a token that was already rotated is treated as
+// theft: the whole chain for that user is revoked, forcing both the
+// attacker and the legitimate holder back to a full login.
+func rotateRefreshToken(db *gorm.DB, plainToken, ip, userAgent string) (newPlain, userID string, err error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashRefreshToken(plainToken)).First(&token).Error; err != nil {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	if token.RevokedAt != nil {
+		RevokeAllRefreshTokens(db, token.UserID)
+		return "", "", ErrRefreshTokenReused
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return "", "", ErrRefreshTokenInvalid
+	}
+
+	now := time.Now()
+	token.RevokedAt = &now
+	if err := db.Save(&token).Error; err != nil {
+		return "", "", err
+	}
+
+	newPlain, err = issueRefreshToken(db, token.UserID, ip, userAgent)
+	if err != nil {
+		return "", "", err
+	}
+	return newPlain, token.UserID, nil
+}
+
+// RevokeAllRefreshTokens marks every still-valid refresh token for userID
+// as revoked. Used on reuse detection and on password change.
+func RevokeAllRefreshTokens(db *gorm.DB, userID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// revokeRefreshToken revokes the single presented token (logout).
+func revokeRefreshToken(db *gorm.DB, plainToken string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("token_hash = ? AND revoked_at IS NULL", hashRefreshToken(plainToken)).
+		Update("revoked_at", time.Now()).Error
+}
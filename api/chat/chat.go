@@ -3,9 +3,15 @@ package chat
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"social-media-app/api/auth"
+	"social-media-app/api/ephemeral"
 	"social-media-app/api/models"
+	"social-media-app/api/pagination"
+	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -18,13 +24,21 @@ type CreateChatRequest struct {
 	ReceiverID string `json:"receiverId" validate:"required"`
 }
 
+type SendMessageRequest struct {
+	Content          string `json:"content" validate:"required"`
+	ExpiresInSeconds int64  `json:"expiresInSeconds"`
+	BurnAfterReading bool   `json:"burnAfterReading"`
+}
+
 type ChatHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
+	db            *gorm.DB
+	redisClient   *redis.Client
+	relationships *services.RelationshipService
+	broker        ws.Broker
 }
 
-func NewChatHandler(db *gorm.DB, redisClient *redis.Client) *ChatHandler {
-	return &ChatHandler{db: db, redisClient: redisClient}
+func NewChatHandler(db *gorm.DB, redisClient *redis.Client, relationships *services.RelationshipService, broker ws.Broker) *ChatHandler {
+	return &ChatHandler{db: db, redisClient: redisClient, relationships: relationships, broker: broker}
 }
 
 func (h *ChatHandler) CreateChat(c *fiber.Ctx) error {
@@ -50,6 +64,12 @@ func (h *ChatHandler) CreateChat(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Receiver not found"})
 	}
 
+	if blocked, err := h.relationships.IsBlocked(req.SenderID, req.ReceiverID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	} else if blocked {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot start a chat with this user"})
+	}
+
 	// Check if chat already exists
 	var existingChat models.Chat
 	if err := h.db.Where("members @> ARRAY[?, ?]::uuid[]", req.SenderID, req.ReceiverID).First(&existingChat).Error; err == nil {
@@ -58,6 +78,7 @@ func (h *ChatHandler) CreateChat(c *fiber.Ctx) error {
 
 	chat := models.Chat{
 		Members: models.UUIDArray{req.SenderID, req.ReceiverID},
+		Type:    services.ChatTypeDirect,
 	}
 
 	if err := h.db.Create(&chat).Error; err != nil {
@@ -73,11 +94,87 @@ func (h *ChatHandler) CreateChat(c *fiber.Ctx) error {
 
 func (h *ChatHandler) UserChats(c *fiber.Ctx) error {
 	userID := c.Params("userId")
+	limit := pagination.ParseLimit(c.Query("limit"))
+	cursor, err := pagination.Decode(c.Query("cursor"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
 	var chats []models.Chat
-	if err := h.db.Where("? = ANY(members)", userID).Find(&chats).Error; err != nil {
+	q := pagination.Apply(h.db.Where("? = ANY(members)", userID), cursor, limit+1)
+	if err := q.Find(&chats).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var nextCursor string
+	if len(chats) > limit {
+		last := chats[limit-1]
+		nextCursor = pagination.Encode(last.CreatedAt, last.ID)
+		chats = chats[:limit]
+	}
+
+	return c.JSON(fiber.Map{"data": chats, "next_cursor": nextCursor})
+}
+
+// SendMessage persists a message on a chat the caller belongs to and
+// publishes a "message" event to every member's gateway channel so all
+// of their connected sockets receive it in real time.
+func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
+	chatID := c.Params("id")
+	senderID := c.Locals("user_id").(string)
+
+	var req SendMessageRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var chat models.Chat
+	if err := h.db.Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Chat not found"})
+	}
+
+	isMember := false
+	for _, memberID := range chat.Members {
+		if memberID == senderID {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Sender is not a member of this chat"})
+	}
+
+	message := models.Message{
+		ChatID:        chatID,
+		SenderID:      senderID,
+		Text:          req.Content,
+		BurnAfterRead: req.BurnAfterReading,
+	}
+	if req.ExpiresInSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInSeconds) * time.Second)
+		message.ExpiresAt = &expiresAt
+	}
+	if err := h.db.Create(&message).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-	return c.JSON(chats)
+
+	if message.ExpiresAt != nil {
+		if err := ephemeral.Schedule(h.redisClient, ephemeral.MessagesExpiryKey, message.ID, *message.ExpiresAt); err != nil {
+			log.Printf("chat: failed to schedule expiry for message %s: %v", message.ID, err)
+		}
+	}
+
+	for _, memberID := range chat.Members {
+		if err := ws.PublishEvent(h.broker, memberID, fiber.Map{
+			"type":    "message",
+			"chat_id": chatID,
+			"message": message,
+		}); err != nil {
+			log.Printf("chat: failed to publish message event to %s: %v", memberID, err)
+		}
+	}
+
+	return c.JSON(message)
 }
 
 func (h *ChatHandler) FindChat(c *fiber.Ctx) error {
@@ -91,13 +188,28 @@ func (h *ChatHandler) FindChat(c *fiber.Ctx) error {
 }
 
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewChatHandler(db, redisClient)
+	relationships := services.NewRelationshipService(db, redisClient)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
+	broker, err := ws.NewBroker(cfg, redisClient)
+	if err != nil {
+		panic("Failed to set up realtime broker: " + err.Error())
+	}
+	handler := NewChatHandler(db, redisClient, relationships, broker)
+	groupHandler := NewGroupHandler(services.NewChatService(db, relationships), redisClient)
 	chat := api.Group("/chat")
-	chat.Post("/", auth.JWTMiddleware(cfg), handler.CreateChat)
+	chat.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreateChat)
+	chat.Post("/:id/message", auth.JWTMiddleware(cfg, redisClient), handler.SendMessage)
 	chat.Get("/:userId", handler.UserChats)
 	chat.Get("/find/:firstId/:secondId", handler.FindChat)
-}
\ No newline at end of file
+
+	chat.Post("/group", auth.JWTMiddleware(cfg, redisClient), groupHandler.CreateGroup)
+	chat.Put("/group/:id", auth.JWTMiddleware(cfg, redisClient), groupHandler.RenameGroup)
+	chat.Post("/group/:id/members", auth.JWTMiddleware(cfg, redisClient), groupHandler.AddMember)
+	chat.Delete("/group/:id/members/:userId", auth.JWTMiddleware(cfg, redisClient), groupHandler.RemoveMember)
+	chat.Post("/group/:id/leave", auth.JWTMiddleware(cfg, redisClient), groupHandler.LeaveGroup)
+	chat.Put("/group/:id/members/:userId/role", auth.JWTMiddleware(cfg, redisClient), groupHandler.SetMemberRole)
+	chat.Post("/group/:id/owner/:userId", auth.JWTMiddleware(cfg, redisClient), groupHandler.TransferOwnership)
+}
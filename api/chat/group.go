@@ -0,0 +1,177 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"social-media-app/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type CreateGroupRequest struct {
+	Name      string   `json:"name" validate:"required"`
+	MemberIDs []string `json:"memberIds"`
+}
+
+type RenameChatRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type AddMemberRequest struct {
+	UserID string `json:"userId" validate:"required"`
+}
+
+type SetRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// GroupHandler exposes group-chat creation and roster/role management.
+// It's kept separate from ChatHandler because it depends on
+// services.ChatService rather than the realtime broker ChatHandler uses.
+type GroupHandler struct {
+	groups      *services.ChatService
+	redisClient *redis.Client
+}
+
+func NewGroupHandler(groups *services.ChatService, redisClient *redis.Client) *GroupHandler {
+	return &GroupHandler{groups, redisClient}
+}
+
+// CreateGroup creates a new group chat owned by the caller.
+func (h *GroupHandler) CreateGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req CreateGroupRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	chat, err := h.groups.CreateGroup(context.Background(), userID, req.Name, req.MemberIDs)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+	return c.JSON(chat)
+}
+
+// RenameGroup renames :id. The caller must be at least an admin.
+func (h *GroupHandler) RenameGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+
+	var req RenameChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	chat, err := h.groups.Rename(chatID, userID, req.Name)
+	if err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "chat_renamed", fiber.Map{"name": chat.Name})
+	return c.JSON(chat)
+}
+
+// AddMember adds a user to :id. The caller must be at least an admin.
+func (h *GroupHandler) AddMember(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+
+	var req AddMemberRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	if err := h.groups.AddMember(chatID, userID, req.UserID); err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "member_added", fiber.Map{"userId": req.UserID})
+	return c.JSON(fiber.Map{"message": "Member added"})
+}
+
+// RemoveMember removes :userId from :id. The caller must be at least an
+// admin; the owner can't be removed this way.
+func (h *GroupHandler) RemoveMember(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+	targetID := c.Params("userId")
+
+	if err := h.groups.RemoveMember(chatID, userID, targetID); err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "member_removed", fiber.Map{"userId": targetID})
+	return c.JSON(fiber.Map{"message": "Member removed"})
+}
+
+// LeaveGroup removes the caller from :id. The owner must transfer
+// ownership before leaving.
+func (h *GroupHandler) LeaveGroup(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+
+	if err := h.groups.Leave(chatID, userID); err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "member_left", fiber.Map{"userId": userID})
+	return c.JSON(fiber.Map{"message": "Left chat"})
+}
+
+// SetMemberRole promotes or demotes :userId between admin and member.
+// The caller must be the chat owner.
+func (h *GroupHandler) SetMemberRole(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+	targetID := c.Params("userId")
+
+	var req SetRoleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	if err := h.groups.SetRole(chatID, userID, targetID, req.Role); err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "role_changed", fiber.Map{"userId": targetID, "role": req.Role})
+	return c.JSON(fiber.Map{"message": "Role updated"})
+}
+
+// TransferOwnership hands ownership of :id to :userId.
+func (h *GroupHandler) TransferOwnership(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	chatID := c.Params("id")
+	targetID := c.Params("userId")
+
+	if err := h.groups.TransferOwnership(chatID, userID, targetID); err != nil {
+		return groupErrorResponse(c, err)
+	}
+	h.publishMemberChange(chatID, "ownership_transferred", fiber.Map{"userId": targetID})
+	return c.JSON(fiber.Map{"message": "Ownership transferred"})
+}
+
+// publishMemberChange broadcasts a roster/role-change event over
+// chat:<id>, the same channel message.HandleWebSocket's connections
+// subscribe to, so connected clients can update their member list live.
+func (h *GroupHandler) publishMemberChange(chatID, eventType string, data interface{}) {
+	payload, err := json.Marshal(fiber.Map{"type": eventType, "chatId": chatID, "data": data})
+	if err != nil {
+		return
+	}
+	h.redisClient.Publish(context.Background(), "chat:"+chatID, payload)
+}
+
+func groupErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrChatNotFound, services.ErrNotChatMember:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrInsufficientRole, services.ErrCannotRemoveOwner:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrAlreadyChatMember:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrBlockedMember:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrInvalidRole:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+}
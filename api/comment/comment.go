@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"social-media-app/api/auth"
 	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
 	"social-media-app/config"
+	"social-media-app/services"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
@@ -26,10 +29,26 @@ type UpdateCommentRequest struct {
 type CommentHandler struct {
 	db          *gorm.DB
 	redisClient *redis.Client
+	comments    *services.CommentService
 }
 
 func NewCommentHandler(db *gorm.DB, redisClient *redis.Client) *CommentHandler {
-	return &CommentHandler{db, redisClient}
+	enqueueDigest := func(ctx context.Context, userID string, event services.DigestEvent) error {
+		return digest.Enqueue(ctx, db, redisClient, userID, digest.Event{
+			Type: event.Type, FromUserID: event.FromUserID, FromUsername: event.FromUsername,
+			PostID: event.PostID, CommentID: event.CommentID, CreatedAt: event.CreatedAt,
+		})
+	}
+	return &CommentHandler{db, redisClient, services.NewCommentService(db, redisClient, enqueueDigest)}
+}
+
+// respondAppError maps an *services.AppError to its HTTP response. If
+// err isn't an AppError, it's treated as an unexpected internal error.
+func respondAppError(c *fiber.Ctx, err error) error {
+	if appErr, ok := err.(*services.AppError); ok {
+		return c.Status(appErr.StatusCode).JSON(fiber.Map{"message": appErr.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 }
 
 func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
@@ -50,11 +69,6 @@ func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
 		}
 	}
 
-	var post models.Post
-	if err := h.db.Where("id = ?", req.PostID).First(&post).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Post not found"})
-	}
-
 	var user models.User
 	if err := h.db.Where("id = ?", req.UserID).First(&user).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
@@ -76,70 +90,26 @@ func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
 		parentID = &req.ParentID
 	}
 
-	comment := models.Comment{
-		PostID:   req.PostID,
-		UserID:   req.UserID,
-		Text:     req.Text,
-		ParentID: parentID,
-	}
-
-	if err := h.db.Create(&comment).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-	}
-
-	post.CommentCount++
-	if err := h.db.Save(&post).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-	}
-
-	var commenter models.User
-	var notifications []models.Notification
-	if err := h.db.Where("id = ?", req.UserID).First(&commenter).Error; err == nil {
-		if post.UserID != req.UserID {
-			notification := models.Notification{
-				UserID:     post.UserID,
-				Type:       "comment",
-				FromUserID: req.UserID,
-				PostID:     &req.PostID,
-				CommentID:  &comment.ID,
-				Message:    commenter.Username + " commented on your post",
-				Read:       false,
-			}
-			h.db.Create(&notification)
-			notifications = append(notifications, notification)
-		}
+	comment, err := h.comments.CreateComment(req.PostID, req.UserID, req.Text, parentID, "")
+	if err != nil {
+		return respondAppError(c, err)
 	}
 
-	if req.ParentID != "" {
-		var parentComment models.Comment
-		if err := h.db.Where("id = ?", req.ParentID).First(&parentComment).Error; err == nil {
-			if parentComment.UserID != req.UserID && parentComment.UserID != post.UserID {
-				notification := models.Notification{
-					UserID:     parentComment.UserID,
-					Type:       "comment_reply",
-					FromUserID: req.UserID,
-					PostID:     &req.PostID,
-					CommentID:  &comment.ID,
-					Message:    commenter.Username + " replied to your comment",
-					Read:       false,
-				}
-				h.db.Create(&notification)
-				notifications = append(notifications, notification)
-			}
-		}
-	}
+	return c.JSON(comment)
+}
 
-	for _, notification := range notifications {
-		notificationJSON, _ := json.Marshal(notification)
-		h.redisClient.Publish(context.Background(), "notification:"+notification.UserID, notificationJSON)
+// CreateRemoteComment persists a reply delivered through an ActivityPub
+// Create activity as a local Comment, so federated replies show up
+// alongside local ones. localUserID stands in for the comment's
+// UserID since that column is uuid-typed and can't hold the remote
+// actor's URI directly; remoteActorURI is recorded separately so the
+// reply can still be attributed to the real federated actor.
+func (h *CommentHandler) CreateRemoteComment(postID, localUserID, remoteActorURI, text string, parentID *string) (models.Comment, error) {
+	comment, err := h.comments.CreateComment(postID, localUserID, text, parentID, remoteActorURI)
+	if err != nil {
+		return models.Comment{}, err
 	}
-
-	commentJSON, _ := json.Marshal(comment)
-	h.redisClient.Set(context.Background(), "comment:"+comment.ID, commentJSON, 3600)
-	h.redisClient.Del(context.Background(), "comments:post:"+req.PostID)
-	h.redisClient.Del(context.Background(), "post:"+req.PostID)
-
-	return c.JSON(comment)
+	return *comment, nil
 }
 
 func (h *CommentHandler) GetComments(c *fiber.Ctx) error {
@@ -175,24 +145,11 @@ func (h *CommentHandler) UpdateComment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
 	}
 
-	var comment models.Comment
-	if err := h.db.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Comment not found"})
-	}
-
-	if comment.UserID != userID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Access denied: You can only edit your own comments"})
-	}
-
-	comment.Text = req.Text
-	if err := h.db.Save(&comment).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	comment, err := h.comments.UpdateComment(commentID, userID, req.Text)
+	if err != nil {
+		return respondAppError(c, err)
 	}
 
-	commentJSON, _ := json.Marshal(comment)
-	h.redisClient.Set(context.Background(), "comment:"+commentID, commentJSON, 3600)
-	h.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
-
 	return c.JSON(comment)
 }
 
@@ -200,31 +157,10 @@ func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
 	commentID := c.Params("id")
 	userID := c.Locals("user_id").(string)
 
-	var comment models.Comment
-	if err := h.db.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Comment not found"})
-	}
-
-	if comment.UserID != userID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Access denied: You can only delete your own comments"})
+	if err := h.comments.DeleteComment(commentID, userID); err != nil {
+		return respondAppError(c, err)
 	}
 
-	var post models.Post
-	if err := h.db.Where("id = ?", comment.PostID).First(&post).Error; err == nil {
-		post.CommentCount--
-		if err := h.db.Save(&post).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-		}
-	}
-
-	if err := h.db.Where("id = ? OR parent_id = ?", commentID, commentID).Delete(&models.Comment{}).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-	}
-
-	h.redisClient.Del(context.Background(), "comment:"+commentID)
-	h.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
-	h.redisClient.Del(context.Background(), "post:"+comment.PostID)
-
 	return c.JSON(fiber.Map{"message": "Comment deleted successfully"})
 }
 
@@ -238,104 +174,25 @@ func (h *CommentHandler) LikeComment(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
 	}
 
-	validReactions := map[string]bool{
-		"like": true, "love": true, "haha": true, "wow": true,
-		"sad": true, "angry": true, "care": true,
-	}
-	if req.ReactionType != "" && !validReactions[req.ReactionType] {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid reaction type"})
-	}
-
-	var comment models.Comment
-	if err := h.db.Where("id = ?", commentID).First(&comment).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Comment not found"})
-	}
-
-	if comment.Reactions == nil {
-		comment.Reactions = make(map[string][]string)
-	}
-
-	currentReaction := ""
-	for rType, users := range comment.Reactions {
-		for _, id := range users {
-			if id == userID {
-				currentReaction = rType
-				break
-			}
-		}
-	}
-
-	if currentReaction == req.ReactionType {
-		comment.Reactions[currentReaction] = removeUser(comment.Reactions[currentReaction], userID)
-		if len(comment.Reactions[currentReaction]) == 0 {
-			delete(comment.Reactions, currentReaction)
-		}
-	} else {
-		if currentReaction != "" {
-			comment.Reactions[currentReaction] = removeUser(comment.Reactions[currentReaction], userID)
-			if len(comment.Reactions[currentReaction]) == 0 {
-				delete(comment.Reactions, currentReaction)
-			}
-		}
-		if req.ReactionType != "" {
-			comment.Reactions[req.ReactionType] = append(comment.Reactions[req.ReactionType], userID)
-		}
-	}
-
-	if err := h.db.Save(&comment).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-	}
-
-	var liker models.User
-	var notification models.Notification
-	if err := h.db.Where("id = ?", userID).First(&liker).Error; err == nil && req.ReactionType != "" {
-		if comment.UserID != userID {
-			notification = models.Notification{
-				UserID:     comment.UserID,
-				Type:       "comment_" + req.ReactionType,
-				FromUserID: userID,
-				PostID:     &comment.PostID,
-				CommentID:  &comment.ID,
-				Message:    liker.Username + " reacted " + req.ReactionType + " to your comment",
-				Read:       false,
-			}
-			h.db.Create(&notification)
-		}
+	_, message, err := h.comments.LikeComment(commentID, userID, req.ReactionType)
+	if err != nil {
+		return respondAppError(c, err)
 	}
 
-	notificationJSON, _ := json.Marshal(notification)
-	h.redisClient.Publish(context.Background(), "notification:"+comment.UserID, notificationJSON)
-
-	commentJSON, _ := json.Marshal(comment)
-	h.redisClient.Set(context.Background(), "comment:"+commentID, commentJSON, 3600)
-	h.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
-
-	message := "Comment " + req.ReactionType
-	if req.ReactionType == "" {
-		message = "Reaction removed"
-	}
 	return c.JSON(fiber.Map{"message": message})
 }
 
-func removeUser(users []string, userID string) []string {
-	for i, id := range users {
-		if id == userID {
-			return append(users[:i], users[i+1:]...)
-		}
-	}
-	return users
-}
-
-func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
+func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) *CommentHandler {
 	handler := NewCommentHandler(db, redisClient)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
 	comment := api.Group("/comment")
-	comment.Post("/", auth.JWTMiddleware(cfg), handler.CreateComment)
+	comment.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreateComment)
 	comment.Get("/post/:postId", handler.GetComments)
-	comment.Put("/:id", auth.JWTMiddleware(cfg), handler.UpdateComment)
-	comment.Delete("/:id", auth.JWTMiddleware(cfg), handler.DeleteComment)
-	comment.Post("/:id/like", auth.JWTMiddleware(cfg), handler.LikeComment)
-}
\ No newline at end of file
+	comment.Put("/:id", auth.JWTMiddleware(cfg, redisClient), handler.UpdateComment)
+	comment.Delete("/:id", auth.JWTMiddleware(cfg, redisClient), handler.DeleteComment)
+	comment.Post("/:id/like", auth.JWTMiddleware(cfg, redisClient), handler.LikeComment)
+	return handler
+}
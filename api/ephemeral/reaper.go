@@ -0,0 +1,142 @@
+// Package ephemeral implements TTL semantics for self-destructing posts
+// and messages: callers schedule a row for deletion by adding its id to a
+// Redis sorted set keyed by expiry epoch, and Reaper polls those sets and
+// removes anything whose score has passed.
+package ephemeral
+
+import (
+	"context"
+	"log"
+	"social-media-app/api/feed"
+	"social-media-app/api/models"
+	"social-media-app/api/ws"
+	"social-media-app/services"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// PostsExpiryKey is the Redis sorted set of expiring post ids, scored
+	// by the Unix timestamp at which they should be deleted.
+	PostsExpiryKey = "ephemeral:posts:expiry"
+	// MessagesExpiryKey is the equivalent sorted set for messages.
+	MessagesExpiryKey = "ephemeral:messages:expiry"
+
+	reapInterval = 10 * time.Second
+	reapBatch    = 100
+)
+
+// Schedule adds id to key with a score of when's Unix timestamp, so a
+// running Reaper deletes it once that time passes. Re-scheduling an id
+// already in the set just updates its score.
+func Schedule(redisClient *redis.Client, key, id string, when time.Time) error {
+	return redisClient.ZAdd(context.Background(), key, redis.Z{
+		Score:  float64(when.Unix()),
+		Member: id,
+	}).Err()
+}
+
+// Reaper polls PostsExpiryKey and MessagesExpiryKey for ids whose expiry
+// has passed, deletes the underlying rows, invalidates their caches, and
+// publishes a "post:deleted"/"message:deleted" event so open clients can
+// drop the content without a refresh.
+type Reaper struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	fanout      *feed.Fanout
+	broker      ws.Broker
+}
+
+func NewReaper(db *gorm.DB, redisClient *redis.Client, relationships *services.RelationshipService, broker ws.Broker) *Reaper {
+	return &Reaper{db: db, redisClient: redisClient, fanout: feed.NewFanout(redisClient, relationships), broker: broker}
+}
+
+// Start polls on reapInterval until ctx is cancelled.
+func (r *Reaper) Start(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reapPosts(ctx)
+			r.reapMessages(ctx)
+		}
+	}
+}
+
+func (r *Reaper) dueIDs(ctx context.Context, key string) []string {
+	ids, err := r.redisClient.ZRangeByScore(ctx, key, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: reapBatch,
+	}).Result()
+	if err != nil {
+		log.Printf("ephemeral: failed to read %s: %v", key, err)
+		return nil
+	}
+	return ids
+}
+
+func (r *Reaper) reapPosts(ctx context.Context) {
+	for _, id := range r.dueIDs(ctx, PostsExpiryKey) {
+		var post models.Post
+		if err := r.db.Where("id = ?", id).First(&post).Error; err != nil {
+			r.redisClient.ZRem(ctx, PostsExpiryKey, id)
+			continue
+		}
+
+		if err := r.db.Delete(&models.Post{}, "id = ?", id).Error; err != nil {
+			log.Printf("ephemeral: failed to delete expired post %s: %v", id, err)
+			continue
+		}
+		r.redisClient.Del(ctx, "post:"+id, "post:"+id+":url")
+		r.redisClient.ZRem(ctx, PostsExpiryKey, id)
+
+		if err := r.fanout.OnDelete(ctx, post); err != nil {
+			log.Printf("ephemeral: failed to remove expired post %s from feeds: %v", id, err)
+		}
+
+		if err := ws.PublishEvent(r.broker, post.UserID, fiber.Map{
+			"type":    "post:deleted",
+			"post_id": id,
+		}); err != nil {
+			log.Printf("ephemeral: failed to publish post:deleted for %s: %v", id, err)
+		}
+	}
+}
+
+func (r *Reaper) reapMessages(ctx context.Context) {
+	for _, id := range r.dueIDs(ctx, MessagesExpiryKey) {
+		var message models.Message
+		if err := r.db.Where("id = ?", id).First(&message).Error; err != nil {
+			r.redisClient.ZRem(ctx, MessagesExpiryKey, id)
+			continue
+		}
+
+		if err := r.db.Delete(&models.Message{}, "id = ?", id).Error; err != nil {
+			log.Printf("ephemeral: failed to delete expired message %s: %v", id, err)
+			continue
+		}
+		r.redisClient.ZRem(ctx, MessagesExpiryKey, id)
+
+		var chat models.Chat
+		if err := r.db.Where("id = ?", message.ChatID).First(&chat).Error; err != nil {
+			continue
+		}
+		for _, memberID := range chat.Members {
+			if err := ws.PublishEvent(r.broker, memberID, fiber.Map{
+				"type":       "message:deleted",
+				"chat_id":    message.ChatID,
+				"message_id": id,
+			}); err != nil {
+				log.Printf("ephemeral: failed to publish message:deleted to %s: %v", memberID, err)
+			}
+		}
+	}
+}
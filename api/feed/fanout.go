@@ -0,0 +1,137 @@
+// Package feed implements a fan-out-on-write timeline: each user has a
+// Redis sorted set of post ids scored by CreatedAt (as UnixNano), so
+// PostHandler.GetTimelinePosts is a single ZSET read instead of a
+// Postgres join across every followed account on every request.
+package feed
+
+import (
+	"context"
+	"social-media-app/api/models"
+	"social-media-app/services"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// Cap bounds how many post ids a single feed keeps; older entries
+	// are trimmed off on every push.
+	Cap = 1000
+
+	// CelebrityFollowerThreshold is the follower count at or above which
+	// an author's posts are no longer pushed into every follower's feed.
+	// Readers merge that author's recent posts in at query time instead
+	// (see HotAuthorsKey).
+	CelebrityFollowerThreshold = 10000
+
+	// HotAuthorsKey is the Redis set of author ids currently over
+	// CelebrityFollowerThreshold.
+	HotAuthorsKey = "hot_authors"
+)
+
+// Key returns the Redis sorted set holding userID's home timeline.
+func Key(userID string) string {
+	return "feed:" + userID
+}
+
+// Fanout pushes new posts into followers' feeds and pulls them back out
+// on delete/expiry. It needs the RelationshipService to know who to fan
+// out to, so it can't live in api/post alone: ephemeral.Reaper also uses
+// it to keep feeds in sync when a post expires.
+type Fanout struct {
+	redisClient   *redis.Client
+	relationships *services.RelationshipService
+}
+
+func NewFanout(redisClient *redis.Client, relationships *services.RelationshipService) *Fanout {
+	return &Fanout{redisClient: redisClient, relationships: relationships}
+}
+
+// IsHot reports whether authorID is currently over the celebrity
+// follower threshold.
+func (f *Fanout) IsHot(ctx context.Context, authorID string) (bool, error) {
+	return f.redisClient.SIsMember(ctx, HotAuthorsKey, authorID).Result()
+}
+
+// FilterHot returns the subset of userIDs currently marked as hot
+// authors, for merging their posts into a follower's timeline at read
+// time instead of relying on push fan-out.
+func (f *Fanout) FilterHot(ctx context.Context, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+	members := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		members[i] = id
+	}
+	flags, err := f.redisClient.SMIsMember(ctx, HotAuthorsKey, members...).Result()
+	if err != nil {
+		return nil, err
+	}
+	hot := make([]string, 0, len(userIDs))
+	for i, isHot := range flags {
+		if isHot {
+			hot = append(hot, userIDs[i])
+		}
+	}
+	return hot, nil
+}
+
+func (f *Fanout) push(ctx context.Context, userID, postID string, score float64) {
+	key := Key(userID)
+	f.redisClient.ZAdd(ctx, key, redis.Z{Score: score, Member: postID})
+	f.redisClient.ZRemRangeByRank(ctx, key, 0, -(Cap + 1))
+}
+
+// OnCreate adds post to its author's own feed, then either fans it out to
+// every follower's feed or, once the author has crossed
+// CelebrityFollowerThreshold, marks them in HotAuthorsKey so readers pull
+// their posts in at query time instead.
+func (f *Fanout) OnCreate(ctx context.Context, post models.Post) error {
+	count, err := f.relationships.FollowerCount(post.UserID)
+	if err != nil {
+		return err
+	}
+
+	if count >= CelebrityFollowerThreshold {
+		f.redisClient.SAdd(ctx, HotAuthorsKey, post.UserID)
+	} else {
+		f.redisClient.SRem(ctx, HotAuthorsKey, post.UserID)
+	}
+
+	score := float64(post.CreatedAt.UnixNano())
+	f.push(ctx, post.UserID, post.ID, score)
+
+	if count >= CelebrityFollowerThreshold {
+		return nil
+	}
+
+	followers, err := f.relationships.Followers(post.UserID, services.RelationshipActive)
+	if err != nil {
+		return err
+	}
+	for _, rel := range followers {
+		f.push(ctx, rel.AccountID, post.ID, score)
+	}
+	return nil
+}
+
+// OnDelete removes post from its author's own feed and, unless the
+// author is a hot author (whose posts were never pushed), every
+// follower's feed too.
+func (f *Fanout) OnDelete(ctx context.Context, post models.Post) error {
+	f.redisClient.ZRem(ctx, Key(post.UserID), post.ID)
+
+	hot, err := f.IsHot(ctx, post.UserID)
+	if err != nil || hot {
+		return err
+	}
+
+	followers, err := f.relationships.Followers(post.UserID, services.RelationshipActive)
+	if err != nil {
+		return err
+	}
+	for _, rel := range followers {
+		f.redisClient.ZRem(ctx, Key(rel.AccountID), post.ID)
+	}
+	return nil
+}
@@ -2,12 +2,17 @@ package friend
 
 import (
 	"context"
-	"encoding/json"
+	"log"
 	"social-media-app/api/auth"
 	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"social-media-app/api/webhook"
+	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services"
+	"social-media-app/services/push"
+
 	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
@@ -17,86 +22,66 @@ type FriendRequestRequest struct {
 }
 
 type FriendRequestHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
+	db            *gorm.DB
+	redisClient   *redis.Client
+	relationships *services.RelationshipService
+	push          *push.Service
 }
 
-func NewFriendRequestHandler(db *gorm.DB, redisClient *redis.Client) *FriendRequestHandler {
-	return &FriendRequestHandler{db, redisClient}
+func NewFriendRequestHandler(db *gorm.DB, redisClient *redis.Client, relationships *services.RelationshipService, pushSvc *push.Service) *FriendRequestHandler {
+	return &FriendRequestHandler{db, redisClient, relationships, pushSvc}
 }
 
-// SendFriendRequest sends a friend request
+// SendFriendRequest sends a friend request to the user named by the
+// body's receiverId, recorded as a
+// Waiting/Pending Relationship pair (see services.RelationshipService).
 func (h *FriendRequestHandler) SendFriendRequest(c *fiber.Ctx) error {
+	senderID := c.Locals("user_id").(string)
+
 	var req FriendRequestRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
 	}
 
-	senderID := c.Locals("user_id").(string)
-	if senderID == req.ReceiverID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot send friend request to yourself"})
+	if _, err := h.relationships.SendRequest(context.Background(), senderID, req.ReceiverID); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
 
-	// Validate UUIDs
-	if _, err := uuid.Parse(req.ReceiverID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid receiverId format"})
+	if err := digest.Enqueue(context.Background(), h.db, h.redisClient, req.ReceiverID, digest.Event{
+		Type: "friend_request", FromUserID: senderID, FromUsername: h.getUsername(senderID),
+	}); err != nil {
+		log.Printf("friend: failed to enqueue digest event for %s: %v", req.ReceiverID, err)
 	}
 
-	// Verify receiver exists
-	var receiver models.User
-	if err := h.db.Where("id = ?", req.ReceiverID).First(&receiver).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Receiver not found"})
+	if err := webhook.Notify(context.Background(), h.db, h.redisClient, req.ReceiverID, webhook.EventFriendRequestSent, fiber.Map{
+		"senderId": senderID, "receiverId": req.ReceiverID,
+	}); err != nil {
+		log.Printf("friend: failed to notify webhooks for %s: %v", req.ReceiverID, err)
 	}
 
-	// Check if request already exists
-	var existing models.FriendRequest
-	if err := h.db.Where("sender_id = ? AND receiver_id = ? AND status = ?", senderID, req.ReceiverID, "pending").First(&existing).Error; err == nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Friend request already sent"})
+	if err := h.push.Send(context.Background(), req.ReceiverID, push.Payload{
+		Title: h.getUsername(senderID),
+		Body:  h.getUsername(senderID) + " sent you a friend request",
+	}, ws.IsOnline(req.ReceiverID), false); err != nil {
+		log.Printf("friend: failed to push to %s: %v", req.ReceiverID, err)
 	}
 
-	friendRequest := models.FriendRequest{
-		SenderID:   senderID,
-		ReceiverID: req.ReceiverID,
-		Status:     "pending",
-	}
-
-	if err := h.db.Create(&friendRequest).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-	}
-
-	// Create notification for receiver
-	var sender models.User
-	var notification models.Notification
-	if err := h.db.Where("id = ?", senderID).First(&sender).Error; err == nil {
-		notification = models.Notification{
-			UserID:     req.ReceiverID,
-			Type:       "friend_request",
-			FromUserID: senderID,
-			Message:    sender.Username + " sent you a friend request",
-			Read:       false,
-		}
-		h.db.Create(&notification)
-	}
-
-	// Publish notification via Redis for WebSocket
-	notificationJSON, _ := json.Marshal(notification)
-	h.redisClient.Publish(context.Background(), "notification:"+req.ReceiverID, notificationJSON)
-
-	return c.JSON(fiber.Map{"message": "Friend request sent", "friendRequest": friendRequest})
+	return c.JSON(fiber.Map{"message": "Friend request sent"})
 }
 
-// ListFriendRequests retrieves pending friend requests for the current user
+// ListFriendRequests returns the requests waiting on the current user to
+// answer, i.e. their Pending relationships.
 func (h *FriendRequestHandler) ListFriendRequests(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
-	var requests []models.FriendRequest
-	if err := h.db.Where("receiver_id = ? AND status = ?", userID, "pending").Find(&requests).Error; err != nil {
+
+	rels, err := h.relationships.List(userID, services.RelationshipPending)
+	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Fetch sender usernames
 	var senderIDs []string
-	for _, req := range requests {
-		senderIDs = append(senderIDs, req.SenderID)
+	for _, rel := range rels {
+		senderIDs = append(senderIDs, rel.RelatedID)
 	}
 	var senders []models.User
 	if len(senderIDs) > 0 {
@@ -110,140 +95,192 @@ func (h *FriendRequestHandler) ListFriendRequests(c *fiber.Ctx) error {
 	}
 
 	response := []fiber.Map{}
-	for _, req := range requests {
+	for _, rel := range rels {
 		response = append(response, fiber.Map{
-			"id":         req.ID,
-			"senderId":   req.SenderID,
-			"senderName": senderMap[req.SenderID],
-			"status":     req.Status,
-			"createdAt":  req.CreatedAt,
+			"senderId":   rel.RelatedID,
+			"senderName": senderMap[rel.RelatedID],
+			"status":     rel.Status,
+			"createdAt":  rel.CreatedAt,
 		})
 	}
 
 	return c.JSON(response)
 }
 
-// ConfirmFriendRequest confirms a friend request
-func (h *FriendRequestHandler) ConfirmFriendRequest(c *fiber.Ctx) error {
-	requestID := c.Params("id")
+// ListFriends lists the current user's relationships, optionally filtered
+// by status (Pending, Waiting, Friend, Blocked, BlockedBy).
+func (h *FriendRequestHandler) ListFriends(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
+	status := c.Query("status")
 
-	var friendRequest models.FriendRequest
-	if err := h.db.Where("id = ? AND receiver_id = ?", requestID, userID).First(&friendRequest).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Friend request not found"})
+	rels, err := h.relationships.List(userID, status)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
+	return c.JSON(rels)
+}
+
+// ConfirmFriendRequest accepts a pending friend request from :userId,
+// creating a chat between the two users.
+func (h *FriendRequestHandler) ConfirmFriendRequest(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	senderID := c.Params("userId")
 
-	if friendRequest.Status != "pending" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Friend request already processed"})
+	if err := h.relationships.Accept(context.Background(), userID, senderID); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
 
-	tx := h.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	var receiver models.User
+	if err := h.db.Where("id = ?", userID).First(&receiver).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
 
-	// Update friend request status
-	friendRequest.Status = "accepted"
-	if err := tx.Save(&friendRequest).Error; err != nil {
-		tx.Rollback()
+	chat := models.Chat{Members: models.UUIDArray{senderID, userID}, Type: services.ChatTypeDirect}
+	if err := h.db.Create(&chat).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Add to Friends field
-	var sender, receiver models.User
-	if err := tx.Where("id = ?", friendRequest.SenderID).First(&sender).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Sender not found"})
+	h.redisClient.Del(context.Background(), "user:"+senderID)
+	h.redisClient.Del(context.Background(), "user:"+userID)
+
+	if err := digest.Enqueue(context.Background(), h.db, h.redisClient, senderID, digest.Event{
+		Type: "friend_accept", FromUserID: userID, FromUsername: receiver.Username,
+	}); err != nil {
+		log.Printf("friend: failed to enqueue digest event for %s: %v", senderID, err)
 	}
-	if err := tx.Where("id = ?", friendRequest.ReceiverID).First(&receiver).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Receiver not found"})
+
+	if err := webhook.Notify(context.Background(), h.db, h.redisClient, senderID, webhook.EventFriendRequestAccepted, fiber.Map{
+		"senderId": senderID, "receiverId": userID,
+	}); err != nil {
+		log.Printf("friend: failed to notify webhooks for %s: %v", senderID, err)
 	}
 
-	sender.Friends = append(sender.Friends, receiver.ID)
-	receiver.Friends = append(receiver.Friends, sender.ID)
+	if err := h.push.Send(context.Background(), senderID, push.Payload{
+		Title: receiver.Username,
+		Body:  receiver.Username + " accepted your friend request",
+	}, ws.IsOnline(senderID), false); err != nil {
+		log.Printf("friend: failed to push to %s: %v", senderID, err)
+	}
 
-	if err := tx.Save(&sender).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	return c.JSON(fiber.Map{"message": "Friend request confirmed", "chatId": chat.ID})
+}
+
+// RejectFriendRequest declines a pending friend request from :userId.
+func (h *FriendRequestHandler) RejectFriendRequest(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	senderID := c.Params("userId")
+
+	if err := h.relationships.Decline(userID, senderID); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
-	if err := tx.Save(&receiver).Error; err != nil {
-		tx.Rollback()
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	return c.JSON(fiber.Map{"message": "Friend request rejected"})
+}
+
+// UnfriendUser removes the mutual Friend relationship with :userId.
+func (h *FriendRequestHandler) UnfriendUser(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	targetID := c.Params("userId")
+
+	if err := h.relationships.Unfriend(userID, targetID); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
+	return c.JSON(fiber.Map{"message": "Unfriended successfully"})
+}
+
+// BlockUser blocks :userId, cancelling any pending friend requests between
+// the two and hiding their posts and chats from each other.
+func (h *FriendRequestHandler) BlockUser(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	targetID := c.Params("userId")
 
-	// Create a new chat for the friends
-	chat := models.Chat{
-		Members: models.UUIDArray{sender.ID, receiver.ID},
+	if err := h.relationships.Block(userID, targetID); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
-	if err := tx.Create(&chat).Error; err != nil {
-		tx.Rollback()
+	return c.JSON(fiber.Map{"message": "User blocked"})
+}
+
+// UnblockUser removes the Blocked/BlockedBy pair with :userId.
+func (h *FriendRequestHandler) UnblockUser(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	targetID := c.Params("userId")
+
+	if err := h.relationships.Unfollow(userID, targetID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-
-	// Create notification for sender
-	notification := models.Notification{
-		UserID:     friendRequest.SenderID,
-		Type:       "friend_accept",
-		FromUserID: userID,
-		Message:    receiver.Username + " accepted your friend request",
-		Read:       false,
+	if err := h.db.Where("account_id = ? AND related_id = ? AND status = ?", userID, targetID, services.RelationshipBlocked).
+		Delete(&models.Relationship{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-	tx.Create(&notification)
-
-	if err := tx.Commit().Error; err != nil {
+	if err := h.db.Where("account_id = ? AND related_id = ? AND status = ?", targetID, userID, services.RelationshipBlockedBy).
+		Delete(&models.Relationship{}).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
+	return c.JSON(fiber.Map{"message": "User unblocked"})
+}
 
-	// Clear user caches
-	h.redisClient.Del(context.Background(), "user:"+friendRequest.SenderID)
-	h.redisClient.Del(context.Background(), "user:"+friendRequest.ReceiverID)
-
-	// Publish notification via Redis for WebSocket
-	notificationJSON, _ := json.Marshal(notification)
-	h.redisClient.Publish(context.Background(), "notification:"+friendRequest.SenderID, notificationJSON)
+// MuteUser stops :userId's realtime chat messages from reaching the
+// current user's WebSocket without changing the relationship itself.
+func (h *FriendRequestHandler) MuteUser(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	targetID := c.Params("userId")
 
-	return c.JSON(fiber.Map{"message": "Friend request confirmed", "chatId": chat.ID})
+	if err := h.relationships.Mute(userID, targetID, true); err != nil {
+		return relationshipErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "User muted"})
 }
 
-// RejectFriendRequest rejects a friend request
-func (h *FriendRequestHandler) RejectFriendRequest(c *fiber.Ctx) error {
-	requestID := c.Params("id")
+// UnmuteUser undoes MuteUser.
+func (h *FriendRequestHandler) UnmuteUser(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
+	targetID := c.Params("userId")
 
-	var friendRequest models.FriendRequest
-	if err := h.db.Where("id = ? AND receiver_id = ?", requestID, userID).First(&friendRequest).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Friend request not found"})
+	if err := h.relationships.Mute(userID, targetID, false); err != nil {
+		return relationshipErrorResponse(c, err)
 	}
+	return c.JSON(fiber.Map{"message": "User unmuted"})
+}
 
-	if friendRequest.Status != "pending" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Friend request already processed"})
+func (h *FriendRequestHandler) getUsername(userID string) string {
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return ""
 	}
+	return user.Username
+}
 
-	friendRequest.Status = "rejected"
-	if err := h.db.Save(&friendRequest).Error; err != nil {
+func relationshipErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrSelfRelationship:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrAlreadyExists:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrBlocked:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrRelationshipFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	default:
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-
-	// Publish notification
-	notificationJSON, _ := json.Marshal(friendRequest)
-	h.redisClient.Publish(context.Background(), "friend_request:"+friendRequest.SenderID, notificationJSON)
-
-	return c.JSON(fiber.Map{"message": "Friend request rejected"})
 }
 
 // Setup configures the friend request routes
-func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewFriendRequestHandler(db, redisClient)
+func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client, pushSvc *push.Service) {
+	relationships := services.NewRelationshipService(db, redisClient)
+	handler := NewFriendRequestHandler(db, redisClient, relationships, pushSvc)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
 	friend := api.Group("/friend")
-	friend.Post("/request", auth.JWTMiddleware(cfg), handler.SendFriendRequest)
-	friend.Get("/requests", auth.JWTMiddleware(cfg), handler.ListFriendRequests)
-	friend.Put("/request/:id/confirm", auth.JWTMiddleware(cfg), handler.ConfirmFriendRequest)
-	friend.Put("/request/:id/reject", auth.JWTMiddleware(cfg), handler.RejectFriendRequest)
-}
\ No newline at end of file
+	friend.Post("/request", auth.JWTMiddleware(cfg, redisClient), handler.SendFriendRequest)
+	friend.Get("/requests", auth.JWTMiddleware(cfg, redisClient), handler.ListFriendRequests)
+	friend.Put("/request/:userId/confirm", auth.JWTMiddleware(cfg, redisClient), handler.ConfirmFriendRequest)
+	friend.Put("/request/:userId/reject", auth.JWTMiddleware(cfg, redisClient), handler.RejectFriendRequest)
+	friend.Post("/block/:userId", auth.JWTMiddleware(cfg, redisClient), handler.BlockUser)
+	friend.Delete("/block/:userId", auth.JWTMiddleware(cfg, redisClient), handler.UnblockUser)
+	friend.Post("/mute/:userId", auth.JWTMiddleware(cfg, redisClient), handler.MuteUser)
+	friend.Delete("/mute/:userId", auth.JWTMiddleware(cfg, redisClient), handler.UnmuteUser)
+	friend.Delete("/:userId", auth.JWTMiddleware(cfg, redisClient), handler.UnfriendUser)
+	friend.Get("/", auth.JWTMiddleware(cfg, redisClient), handler.ListFriends)
+}
@@ -3,10 +3,16 @@ package message
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"social-media-app/api/auth"
 	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services"
 	"strings" // Added strings import
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"github.com/google/uuid"
@@ -15,123 +21,277 @@ import (
 )
 
 type MessageRequest struct {
-	ChatID   string `json:"chatId" validate:"required"`
-	SenderID string `json:"senderId" validate:"required"`
-	Text     string `json:"text" validate:"required"`
+	ChatID string `json:"chatId" validate:"required"`
+	Text   string `json:"text" validate:"required"`
 }
 
-type ActiveUser struct {
-	UserID   string
-	SocketID string
+// wsMessage is the inbound WebSocket envelope. Type distinguishes a
+// plain chat send from the realtime typing/read/delivered signals;
+// Type == "" is treated as "message" so older clients sending the bare
+// {chatId, text} shape keep working unchanged. The sender is always the
+// connection's JWT-verified user, never a client-supplied field.
+type wsMessage struct {
+	Type      string `json:"type"`
+	ChatID    string `json:"chatId"`
+	Text      string `json:"text"`
+	MessageID string `json:"messageId"`
+	// Typing is "start" or "stop", only read when Type == "typing".
+	Typing string `json:"typing"`
 }
 
 type MessageHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
-	activeUsers map[string]ActiveUser
+	db            *gorm.DB
+	redisClient   *redis.Client
+	relationships *services.RelationshipService
+	messages      *services.MessageService
 }
 
-func NewMessageHandler(db *gorm.DB, redisClient *redis.Client) *MessageHandler {
+func NewMessageHandler(db *gorm.DB, redisClient *redis.Client, relationships *services.RelationshipService, messages *services.MessageService) *MessageHandler {
 	return &MessageHandler{
-		db:          db,
-		redisClient: redisClient,
-		activeUsers: make(map[string]ActiveUser),
+		db:            db,
+		redisClient:   redisClient,
+		relationships: relationships,
+		messages:      messages,
+	}
+}
+
+// respondAppError maps an *services.AppError to its HTTP response. If
+// err isn't an AppError, it's treated as an unexpected internal error.
+func respondAppError(c *fiber.Ctx, err error) error {
+	if appErr, ok := err.(*services.AppError); ok {
+		return c.Status(appErr.StatusCode).JSON(fiber.Map{"message": appErr.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+}
+
+// publishChatEvent wraps data in a {type, chatId, data} envelope and
+// publishes it on the chat's Redis channel, so every connection
+// subscribed to it (see HandleWebSocket) can dispatch on Type without
+// needing to know each event's payload shape up front.
+func (h *MessageHandler) publishChatEvent(chatID, eventType string, data interface{}) {
+	payload, err := json.Marshal(fiber.Map{"type": eventType, "chatId": chatID, "data": data})
+	if err != nil {
+		return
+	}
+	h.redisClient.Publish(context.Background(), "chat:"+chatID, payload)
+}
+
+// shouldSuppress reports whether a "chat:" channel payload is a
+// new-message event from someone userID has muted. The message itself
+// is still stored and counted toward unread status; only this
+// connection's realtime delivery of it is skipped, since a chat
+// channel is shared by every member and muting is per-recipient.
+func (h *MessageHandler) shouldSuppress(userID string, payload string) bool {
+	var envelope struct {
+		Type string          `json:"type"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil || envelope.Type != "new-message" {
+		return false
 	}
+	var message models.Message
+	if err := json.Unmarshal(envelope.Data, &message); err != nil {
+		return false
+	}
+	muted, err := h.relationships.IsMuted(userID, message.SenderID)
+	return err == nil && muted
 }
 
+// AddMessage parses and validates the request, then delegates to
+// services.MessageService for the actual persistence/authorization/
+// fan-out logic, so the same logic is reusable from the WebSocket read
+// loop below without a Fiber context.
 func (h *MessageHandler) AddMessage(c *fiber.Ctx) error {
 	var req MessageRequest
 	if err := c.BodyParser(&req); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
 	}
 
-	// Validate UUIDs
 	if _, err := uuid.Parse(req.ChatID); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid chatId format"})
 	}
-	if _, err := uuid.Parse(req.SenderID); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid senderId format"})
+
+	userID := c.Locals("user_id").(string)
+	message, err := h.messages.CreateMessage(req.ChatID, userID, req.Text)
+	if err != nil {
+		return respondAppError(c, err)
 	}
+	return c.JSON(message)
+}
 
-	// Verify chat exists
-	var chat models.Chat
-	if err := h.db.Where("id = ?", req.ChatID).First(&chat).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Chat not found"})
+func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
+	chatID := c.Params("chatId")
+	userID := c.Locals("user_id").(string)
+	if err := h.requireMember(chatID, userID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Verify sender exists
-	var sender models.User
-	if err := h.db.Where("id = ?", req.SenderID).First(&sender).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Sender not found"})
+	var messages []models.Message
+	if err := h.db.Where("chat_id = ? AND (expires_at IS NULL OR expires_at > ?)", chatID, time.Now()).Find(&messages).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
+	return c.JSON(messages)
+}
 
-	// Check if sender is a member of the chat
-	isMember := false
-	for _, memberID := range chat.Members {
-		if memberID == req.SenderID {
-			isMember = true
-			break
-		}
+// GetMessageStatus reports every recipient's delivered/read state for
+// messageId, so a sender's client can render per-message receipts.
+func (h *MessageHandler) GetMessageStatus(c *fiber.Ctx) error {
+	messageID := c.Params("messageId")
+	if _, err := uuid.Parse(messageID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid messageId format"})
+	}
+
+	userID := c.Locals("user_id").(string)
+	var message models.Message
+	if err := h.db.Where("id = ?", messageID).First(&message).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Message not found"})
 	}
-	if !isMember {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Sender is not a member of this chat"})
+	if err := h.requireMember(message.ChatID, userID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Check if users are friends (using Friends field)
-	var receiver models.User
-	for _, memberID := range chat.Members {
-		if memberID != req.SenderID {
-			if err := h.db.Where("id = ?", memberID).First(&receiver).Error; err != nil {
-				return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Receiver not found"})
-			}
-			isFriend := false
-			for _, friendID := range sender.Friends {
-				if friendID == memberID {
-					isFriend = true
-					break
-				}
-			}
-			if !isFriend {
-				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Users must be friends to send messages"})
-			}
-		}
+	var statuses []models.MessageStatus
+	if err := h.db.Where("message_id = ?", messageID).Find(&statuses).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
+	return c.JSON(statuses)
+}
 
-	message := models.Message{
-		ChatID:   req.ChatID,
-		SenderID: req.SenderID,
-		Text:     req.Text,
+// GetUnreadCount reports how many of chatId's messages from other
+// members the calling user hasn't acknowledged with a "read" event yet.
+func (h *MessageHandler) GetUnreadCount(c *fiber.Ctx) error {
+	chatID := c.Params("chatId")
+	if _, err := uuid.Parse(chatID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid chatId format"})
+	}
+
+	userID := c.Locals("user_id").(string)
+	if err := h.requireMember(chatID, userID); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
 	}
-	if err := h.db.Create(&message).Error; err != nil {
+
+	var count int64
+	readMessageIDs := h.db.Model(&models.MessageStatus{}).
+		Select("message_id").
+		Where("user_id = ? AND read_at IS NOT NULL", userID)
+	if err := h.db.Model(&models.Message{}).
+		Where("chat_id = ? AND sender_id <> ?", chatID, userID).
+		Where("id NOT IN (?)", readMessageIDs).
+		Count(&count).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	messageJSON, _ := json.Marshal(message)
-	h.redisClient.Publish(context.Background(), "chat:"+req.ChatID, messageJSON)
-	return c.JSON(message)
+	return c.JSON(fiber.Map{"chatId": chatID, "unreadCount": count})
 }
 
-func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
-	chatID := c.Params("chatId")
+// requireMember returns an error unless userID belongs to chatID, so
+// status/unread-count lookups can't be used to probe another chat.
+func (h *MessageHandler) requireMember(chatID, userID string) error {
+	var chat models.Chat
+	if err := h.db.Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return errors.New("Chat not found")
+	}
+	for _, memberID := range chat.Members {
+		if memberID == userID {
+			return nil
+		}
+	}
+	return errors.New("Access denied: not a member of this chat")
+}
+
+// markDelivered upserts a MessageStatus row recording that userID's
+// client has received messageID, leaving ReadAt untouched if the
+// recipient already has one.
+func (h *MessageHandler) markDelivered(messageID, userID string) (*models.MessageStatus, error) {
+	var status models.MessageStatus
+	err := h.db.Where("message_id = ? AND user_id = ?", messageID, userID).First(&status).Error
+	now := time.Now()
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = models.MessageStatus{MessageID: messageID, UserID: userID, DeliveredAt: &now}
+		if err := h.db.Create(&status).Error; err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case status.DeliveredAt == nil:
+		status.DeliveredAt = &now
+		if err := h.db.Save(&status).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &status, nil
+}
+
+// markRead acknowledges every message in chatID up to and including
+// upToMessageID (by CreatedAt) that wasn't sent by userID, upserting a
+// MessageStatus row with ReadAt (and DeliveredAt, if still unset) set
+// to now for each. It returns the affected message IDs.
+func (h *MessageHandler) markRead(chatID, userID, upToMessageID string) ([]string, error) {
+	var upTo models.Message
+	if err := h.db.Where("id = ? AND chat_id = ?", upToMessageID, chatID).First(&upTo).Error; err != nil {
+		return nil, err
+	}
+
 	var messages []models.Message
-	if err := h.db.Where("chat_id = ?", chatID).Find(&messages).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	if err := h.db.Where("chat_id = ? AND sender_id <> ? AND created_at <= ?", chatID, userID, upTo.CreatedAt).Find(&messages).Error; err != nil {
+		return nil, err
 	}
-	return c.JSON(messages)
+
+	now := time.Now()
+	acknowledged := make([]string, 0, len(messages))
+	for _, message := range messages {
+		var status models.MessageStatus
+		err := h.db.Where("message_id = ? AND user_id = ?", message.ID, userID).First(&status).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = models.MessageStatus{MessageID: message.ID, UserID: userID, DeliveredAt: &now, ReadAt: &now}
+			if err := h.db.Create(&status).Error; err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, err
+		case status.ReadAt == nil:
+			status.ReadAt = &now
+			if status.DeliveredAt == nil {
+				status.DeliveredAt = &now
+			}
+			if err := h.db.Save(&status).Error; err != nil {
+				return nil, err
+			}
+		}
+		acknowledged = append(acknowledged, message.ID)
+	}
+
+	return acknowledged, nil
 }
 
 func (h *MessageHandler) HandleWebSocket(c *websocket.Conn) {
-	userID := c.Query("userId")
+	userID, _ := c.Locals("user_id").(string)
 	if userID == "" {
 		c.Close()
 		return
 	}
 
-	h.activeUsers[userID] = ActiveUser{UserID: userID, SocketID: c.RemoteAddr().String()}
-	h.redisClient.Publish(context.Background(), "users", h.getActiveUsersJSON())
+	connID := uuid.NewString()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ws.JoinPresence(ctx, userID, connID)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ws.RefreshPresence(ctx, userID, connID)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	ctx := context.Background()
-	channels := []string{"friend_request:" + userID}
+	channels := []string{"notification:" + userID}
 	var chatIDs []string
 	if err := h.db.Model(&models.Chat{}).Where("? = ANY(members)", userID).Pluck("id", &chatIDs).Error; err != nil {
 		c.WriteJSON(fiber.Map{"message": "Failed to fetch chats"})
@@ -147,118 +307,103 @@ func (h *MessageHandler) HandleWebSocket(c *websocket.Conn) {
 	go func() {
 		ch := pubsub.Channel()
 		for msg := range ch {
-			var data interface{}
 			if strings.HasPrefix(msg.Channel, "chat:") {
-				var message models.Message
-				if err := json.Unmarshal([]byte(msg.Payload), &message); err == nil {
-					data = message
+				if h.shouldSuppress(userID, msg.Payload) {
+					continue
 				}
-			} else if strings.HasPrefix(msg.Channel, "friend_request:") {
-				var friendRequest models.FriendRequest
-				if err := json.Unmarshal([]byte(msg.Payload), &friendRequest); err == nil {
-					data = fiber.Map{
-						"type":         "friend_request",
-						"friendRequest": friendRequest,
-					}
+				// Already a {type, chatId, data} envelope (see
+				// publishChatEvent); forward it verbatim.
+				c.WriteMessage(websocket.TextMessage, []byte(msg.Payload))
+			} else if strings.HasPrefix(msg.Channel, "notification:") {
+				var notification models.Notification
+				if err := json.Unmarshal([]byte(msg.Payload), &notification); err == nil {
+					c.WriteJSON(fiber.Map{"type": "notification", "data": notification})
 				}
 			}
-			if data != nil {
-				c.WriteJSON(data)
-			}
 		}
 	}()
 
 	for {
-		var req MessageRequest
+		var req wsMessage
 		if err := c.ReadJSON(&req); err != nil {
-			delete(h.activeUsers, userID)
-			h.redisClient.Publish(context.Background(), "users", h.getActiveUsersJSON())
 			break
 		}
 
-		// Validate and save message
-		if _, err := uuid.Parse(req.ChatID); err != nil {
-			c.WriteJSON(fiber.Map{"message": "Invalid chatId format"})
-			continue
-		}
-		if _, err := uuid.Parse(req.SenderID); err != nil {
-			c.WriteJSON(fiber.Map{"message": "Invalid senderId format"})
-			continue
-		}
-
-		var chat models.Chat
-		if err := h.db.Where("id = ?", req.ChatID).First(&chat).Error; err != nil {
-			c.WriteJSON(fiber.Map{"message": "Chat not found"})
-			continue
-		}
+		switch req.Type {
+		case "typing":
+			if _, err := uuid.Parse(req.ChatID); err != nil {
+				c.WriteJSON(fiber.Map{"message": "Invalid chatId format"})
+				continue
+			}
+			if err := h.requireMember(req.ChatID, userID); err != nil {
+				c.WriteJSON(fiber.Map{"message": err.Error()})
+				continue
+			}
+			h.publishChatEvent(req.ChatID, "typing", fiber.Map{"userId": userID, "typing": req.Typing})
 
-		isMember := false
-		for _, memberID := range chat.Members {
-			if memberID == req.SenderID {
-				isMember = true
-				break
+		case "delivered":
+			if _, err := uuid.Parse(req.MessageID); err != nil {
+				c.WriteJSON(fiber.Map{"message": "Invalid messageId format"})
+				continue
 			}
-		}
-		if !isMember {
-			c.WriteJSON(fiber.Map{"message": "Sender is not a member of this chat"})
-			continue
-		}
+			status, err := h.markDelivered(req.MessageID, userID)
+			if err != nil {
+				c.WriteJSON(fiber.Map{"message": "Failed to mark delivered"})
+				continue
+			}
+			h.publishChatEvent(req.ChatID, "delivered", fiber.Map{"userId": userID, "messageId": status.MessageID})
 
-		var sender models.User
-		if err := h.db.Where("id = ?", req.SenderID).First(&sender).Error; err != nil {
-			c.WriteJSON(fiber.Map{"message": "Sender not found"})
-			continue
-		}
+		case "read":
+			if _, err := uuid.Parse(req.ChatID); err != nil {
+				c.WriteJSON(fiber.Map{"message": "Invalid chatId format"})
+				continue
+			}
+			if _, err := uuid.Parse(req.MessageID); err != nil {
+				c.WriteJSON(fiber.Map{"message": "Invalid messageId format"})
+				continue
+			}
+			acknowledged, err := h.markRead(req.ChatID, userID, req.MessageID)
+			if err != nil {
+				c.WriteJSON(fiber.Map{"message": "Failed to mark read"})
+				continue
+			}
+			h.publishChatEvent(req.ChatID, "read", fiber.Map{"userId": userID, "messageIds": acknowledged})
 
-		var receiver models.User
-		for _, memberID := range chat.Members {
-			if memberID != req.SenderID {
-				if err := h.db.Where("id = ?", memberID).First(&receiver).Error; err != nil {
-					c.WriteJSON(fiber.Map{"message": "Receiver not found"})
-					continue
-				}
-				isFriend := false
-				for _, friendID := range sender.Friends {
-					if friendID == memberID {
-						isFriend = true
-						break
-					}
-				}
-				if !isFriend {
-					c.WriteJSON(fiber.Map{"message": "Users must be friends to send messages"})
-					continue
-				}
+		case "", "message":
+			if _, err := uuid.Parse(req.ChatID); err != nil {
+				c.WriteJSON(fiber.Map{"message": "Invalid chatId format"})
+				continue
 			}
-		}
 
-		message := models.Message{
-			ChatID:   req.ChatID,
-			SenderID: req.SenderID,
-			Text:     req.Text,
-		}
-		if err := h.db.Create(&message).Error; err != nil {
-			c.WriteJSON(fiber.Map{"message": "Failed to save message"})
-			continue
+			if _, err := h.messages.CreateMessage(req.ChatID, userID, req.Text); err != nil {
+				c.WriteJSON(fiber.Map{"message": err.Error()})
+				continue
+			}
 		}
-
-		messageJSON, _ := json.Marshal(message)
-		h.redisClient.Publish(context.Background(), "chat:"+req.ChatID, messageJSON)
 	}
-}
 
-func (h *MessageHandler) getActiveUsersJSON() string {
-	usersJSON, _ := json.Marshal(h.activeUsers)
-	return string(usersJSON)
+	ws.LeavePresence(context.Background(), userID, connID)
 }
 
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewMessageHandler(db, redisClient)
+	relationships := services.NewRelationshipService(db, redisClient)
+	enqueueDigest := func(ctx context.Context, userID string, event services.DigestEvent) error {
+		return digest.Enqueue(ctx, db, redisClient, userID, digest.Event{
+			Type: event.Type, FromUserID: event.FromUserID, FromUsername: event.FromUsername,
+			PostID: event.PostID, CommentID: event.CommentID, CreatedAt: event.CreatedAt,
+		})
+	}
+	notifications := services.NewNotificationService(db, redisClient, enqueueDigest)
+	messages := services.NewMessageService(db, redisClient, relationships, notifications)
+	handler := NewMessageHandler(db, redisClient, relationships, messages)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
 	message := api.Group("/message")
-	message.Post("/", auth.JWTMiddleware(cfg), handler.AddMessage)
-	message.Get("/:chatId", auth.JWTMiddleware(cfg), handler.GetMessages)
-	api.Get("/ws", websocket.New(handler.HandleWebSocket))
-}
\ No newline at end of file
+	message.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.AddMessage)
+	message.Get("/:chatId", auth.JWTMiddleware(cfg, redisClient), handler.GetMessages)
+	message.Get("/:messageId/status", auth.JWTMiddleware(cfg, redisClient), handler.GetMessageStatus)
+	message.Get("/chat/:chatId/unread", auth.JWTMiddleware(cfg, redisClient), handler.GetUnreadCount)
+	api.Get("/ws", auth.WebSocketJWTMiddleware(cfg, redisClient), websocket.New(handler.HandleWebSocket))
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -22,15 +23,15 @@ func (a *StringArray) Scan(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("failed to scan array: not a string")
 	}
-	
+
 	str = strings.TrimPrefix(str, "{")
 	str = strings.TrimSuffix(str, "}")
-	
+
 	if str == "" {
 		*a = StringArray{}
 		return nil
 	}
-	
+
 	*a = StringArray(strings.Split(str, ","))
 	return nil
 }
@@ -42,6 +43,45 @@ func (a StringArray) Value() (driver.Value, error) {
 	return "{" + strings.Join(a, ",") + "}", nil
 }
 
+// NotificationLink is a single actionable deep-link attached to a
+// notification, e.g. one taking the recipient straight to a broadcast's
+// referenced post.
+type NotificationLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// NotificationLinks is a custom type to persist a notification's deep-links
+// as a jsonb column.
+type NotificationLinks []NotificationLink
+
+func (l *NotificationLinks) Scan(value interface{}) error {
+	if value == nil {
+		*l = NotificationLinks{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("failed to scan links: not a []byte or string")
+		}
+		bytes = []byte(str)
+	}
+	if len(bytes) == 0 {
+		*l = NotificationLinks{}
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+func (l NotificationLinks) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
 // UUIDArray is a custom type to handle PostgreSQL uuid arrays
 type UUIDArray []string
 
@@ -54,15 +94,15 @@ func (a *UUIDArray) Scan(value interface{}) error {
 	if !ok {
 		return fmt.Errorf("failed to scan uuid array: not a string")
 	}
-	
+
 	str = strings.TrimPrefix(str, "{")
 	str = strings.TrimSuffix(str, "}")
-	
+
 	if str == "" {
 		*a = UUIDArray{}
 		return nil
 	}
-	
+
 	*a = UUIDArray(strings.Split(str, ","))
 	return nil
 }
@@ -83,13 +123,13 @@ func (a UUIDArray) Value() (driver.Value, error) {
 }
 
 type User struct {
-	ID             string      `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	Username       string      `gorm:"uniqueIndex;not null"`
-	Password       string      `gorm:"not null"`
-	Firstname      string      `gorm:"not null"`
-	Lastname       string      `gorm:"not null"`
-	IsAdmin        bool        `gorm:"default:false"`
-	Email          string      `gorm:"not null"`
+	ID             string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Username       string `gorm:"uniqueIndex;not null"`
+	Password       string `gorm:"not null"`
+	Firstname      string `gorm:"not null"`
+	Lastname       string `gorm:"not null"`
+	IsAdmin        bool   `gorm:"default:false"`
+	Email          string `gorm:"not null"`
 	ProfilePicture string
 	CoverPicture   string
 	About          string
@@ -99,87 +139,369 @@ type User struct {
 	Country        string
 	Followers      StringArray `gorm:"type:text[]"`
 	Following      StringArray `gorm:"type:text[]"`
-	Friends        StringArray `gorm:"type:text[]"`
+	PublicKey      string      `gorm:"type:text"`
+	PrivateKey     string      `gorm:"type:text"`
+	// DigestInterval controls how often reaction/comment/follow
+	// notifications are batched into an email: "immediate" (realtime
+	// WebSocket/push only, no digest email), "15m", "1h", "daily", or
+	// "off" (no email at all).
+	DigestInterval string `gorm:"not null;default:immediate"`
+	EmailEnabled   bool   `gorm:"default:true"`
 	CreatedAt      time.Time
 	UpdatedAt      time.Time
-	Posts          []Post      `gorm:"foreignKey:UserID"`
-	Chats          []Chat      `gorm:"many2many:user_chats"`
-	Messages       []Message   `gorm:"foreignKey:SenderID"`
-	Comments       []Comment   `gorm:"foreignKey:UserID"`
+	Posts          []Post    `gorm:"foreignKey:UserID"`
+	Chats          []Chat    `gorm:"many2many:user_chats"`
+	Messages       []Message `gorm:"foreignKey:SenderID"`
+	Comments       []Comment `gorm:"foreignKey:UserID"`
+}
+
+// RemoteUser caches a federated ActivityPub actor so follow relationships
+// can span local and remote users.
+type RemoteUser struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	ActorID     string `gorm:"uniqueIndex;not null"` // canonical actor URI
+	Inbox       string `gorm:"not null"`
+	SharedInbox string
+	PublicKey   string `gorm:"type:text;not null"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 type Post struct {
-	ID           string              `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	UserID       string              `gorm:"type:uuid;not null"`
-	Desc         string
-	Reactions    map[string][]string `gorm:"serializer:json"`
-	CommentCount int
-	Image        string
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	Comments     []Comment           `gorm:"foreignKey:PostID"`
+	ID            string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID        string `gorm:"type:uuid;not null"`
+	Desc          string
+	Reactions     map[string][]string `gorm:"serializer:json"`
+	CommentCount  int
+	Image         string
+	ExpiresAt     *time.Time
+	BurnAfterRead bool `gorm:"default:false"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Comments      []Comment `gorm:"foreignKey:PostID"`
 }
 
 type Comment struct {
-	ID         string              `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	PostID     string              `gorm:"type:uuid;not null"`
-	UserID     string              `gorm:"type:uuid;not null"`
-	Text       string              `gorm:"not null"`
-	ParentID   *string             `gorm:"type:uuid"`
-	Reactions  map[string][]string `gorm:"serializer:json"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID        string              `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	PostID    string              `gorm:"type:uuid;not null"`
+	UserID    string              `gorm:"type:uuid;not null"`
+	Text      string              `gorm:"not null"`
+	ParentID  *string             `gorm:"type:uuid"`
+	Reactions map[string][]string `gorm:"serializer:json"`
+	// RemoteActorURI is set when this comment was delivered via
+	// ActivityPub from a federated actor, who has no local uuid of
+	// their own. Empty for comments created by local users.
+	RemoteActorURI string `gorm:"default:''"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
 }
 
 type Chat struct {
-	ID        string      `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	Members   UUIDArray   `gorm:"type:uuid[]"`
+	ID      string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Members UUIDArray `gorm:"type:uuid[]"`
+	// Name and OwnerID are only set for Type == "group"; a direct chat's
+	// two participants come entirely from Members. Type defaults to
+	// "direct" so existing rows created before group chats keep working.
+	Name      string
+	OwnerID   string `gorm:"type:uuid"`
+	Type      string `gorm:"default:direct"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Messages  []Message   `gorm:"foreignKey:ChatID"`
+	Messages  []Message `gorm:"foreignKey:ChatID"`
+}
+
+// ChatMember records one user's role in a group Chat and when they
+// joined it. Direct chats don't use this table; their membership is
+// just the two IDs in Chat.Members.
+type ChatMember struct {
+	ID       string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	ChatID   string `gorm:"type:uuid;not null;uniqueIndex:idx_chat_members_chat_user"`
+	UserID   string `gorm:"type:uuid;not null;uniqueIndex:idx_chat_members_chat_user"`
+	Role     string `gorm:"not null"`
+	JoinedAt time.Time
 }
 
 type Message struct {
-	ID        string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	ChatID    string    `gorm:"type:uuid"`
-	SenderID  string    `gorm:"type:uuid"`
-	Text      string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID            string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	ChatID        string `gorm:"type:uuid"`
+	SenderID      string `gorm:"type:uuid"`
+	Text          string
+	ExpiresAt     *time.Time
+	BurnAfterRead bool `gorm:"default:false"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
-type FriendRequest struct {
-	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	SenderID    string    `gorm:"type:uuid;not null"`
-	ReceiverID  string    `gorm:"type:uuid;not null"`
-	Status      string    `gorm:"not null;default:pending"`
+// MessageStatus records one recipient's delivery/read progress for one
+// Message: DeliveredAt is set once their client acknowledges receiving
+// it, ReadAt once they've seen it. A recipient who hasn't acknowledged
+// either yet simply has no row, rather than a row with both fields nil,
+// so "has this been delivered" is a plain existence-plus-nil-check.
+type MessageStatus struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	MessageID   string `gorm:"type:uuid;not null;uniqueIndex:idx_message_status_message_user"`
+	UserID      string `gorm:"type:uuid;not null;uniqueIndex:idx_message_status_message_user"`
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
+// Attachment is an uploaded file (image, thumbnail, etc.) stored in a
+// pluggable Storage backend. User.ProfilePicture/CoverPicture reference
+// Attachment.ID rather than a raw path so storage details can change
+// without touching callers.
+type Attachment struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	OwnerID   string `gorm:"type:uuid;not null"`
+	Bucket    string `gorm:"not null"`
+	Key       string `gorm:"not null"`
+	Mime      string `gorm:"not null"`
+	Size      int64  `gorm:"not null"`
+	ThumbKey  string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Relationship is a directed edge between two users: AccountID's view of
+// RelatedID. Status is one of Pending (friend request awaiting accept),
+// Active (following, or an accepted friendship), Blocked (AccountID
+// blocked RelatedID), or BlockedBy (the reciprocal row RelatedID creates
+// when it blocks AccountID).
+type Relationship struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	AccountID string `gorm:"type:uuid;not null;index:idx_relationships_account"`
+	RelatedID string `gorm:"type:uuid;not null;index:idx_relationships_related"`
+	Status    string `gorm:"not null"`
+	// Muted is AccountID's own choice to stop seeing RelatedID's
+	// realtime chat messages without affecting the relationship Status
+	// itself, so a Friend can be muted without being unfriended.
+	Muted     bool `gorm:"default:false"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// RefreshToken is an opaque, long-lived credential a client exchanges for
+// a new access token without re-authenticating. Only TokenHash (a
+// SHA-256 digest) is stored, never the plaintext. Rotating a token sets
+// RevokedAt and creates its successor; presenting an already-revoked
+// token again is reuse, and the caller should revoke every row for
+// UserID rather than just this one.
+type RefreshToken struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID    string `gorm:"type:uuid;not null;index"`
+	TokenHash string `gorm:"type:text;not null;uniqueIndex"`
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
 type Notification struct {
-	ID          string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	UserID      string    `gorm:"type:uuid;not null"`
-	Type        string    `gorm:"not null"`
-	FromUserID  string    `gorm:"type:uuid;not null"`
-	PostID      *string   `gorm:"type:uuid"`
-	CommentID   *string   `gorm:"type:uuid"`
-	Message     string    `gorm:"not null"`
-	Read        bool      `gorm:"default:false"`
+	ID         string            `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID     string            `gorm:"type:uuid;not null"`
+	Type       string            `gorm:"not null"`
+	FromUserID string            `gorm:"type:uuid;not null"`
+	PostID     *string           `gorm:"type:uuid"`
+	CommentID  *string           `gorm:"type:uuid"`
+	Message    string            `gorm:"not null"`
+	Links      NotificationLinks `gorm:"type:jsonb"`
+	Read       bool              `gorm:"default:false"`
+	ReadAt     *time.Time
+	// IsForcePush asks services/push to deliver a push notification even
+	// if the recipient has an active WebSocket connection, bypassing the
+	// usual "only push when offline" rule.
+	IsForcePush bool `gorm:"default:false"`
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
-type Product struct {
+// DeviceToken is a user's registered mobile push target for
+// services/push: which provider (ios -> APNs, android -> FCM) to route
+// through and the opaque token that provider gave the device.
+// RegisterToken upserts by (UserID, Token) so re-registering the same
+// device just refreshes LastSeenAt instead of duplicating the row.
+type DeviceToken struct {
+	ID         string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID     string `gorm:"type:uuid;not null;index"`
+	Platform   string `gorm:"not null"` // ios | android
+	Token      string `gorm:"not null;uniqueIndex"`
+	LastSeenAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// CallRecording tracks an Agora Cloud Recording session started for a
+// signaling channel: the acquire/start response fields needed to later
+// stop it, and where the recorded file ends up once Agora uploads it.
+type CallRecording struct {
 	ID         string    `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
-	Title      string
-	MobileIcon string
-	DesktopIcon string
-	BrandIcon  string
+	Channel    string    `gorm:"not null;index"`
+	UID        string    `gorm:"not null"`
+	SID        string    `gorm:"not null"`
+	ResourceID string    `gorm:"not null"`
+	StartedAt  time.Time `gorm:"not null"`
+	StoppedAt  *time.Time
+	StorageURL string
 	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// Call is a signaling-level group call (1:1 or group), tracked through
+// a ringing -> active -> ended/missed state machine by api/ws.CallManager.
+type Call struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Channel     string `gorm:"not null;uniqueIndex"`
+	InitiatorID string `gorm:"type:uuid;not null"`
+	Type        string `gorm:"not null"`                   // audio | video
+	Status      string `gorm:"not null;default:'ringing'"` // ringing | active | ended | missed
+	StartedAt   time.Time
+	EndedAt     *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// CallParticipant is one invitee's state within a Call.
+type CallParticipant struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	CallID    string `gorm:"type:uuid;not null;index"`
+	UserID    string `gorm:"type:uuid;not null"`
+	State     string `gorm:"not null;default:'ringing'"` // ringing | joined | rejected | missed | left
+	JoinedAt  *time.Time
+	LeftAt    *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookSubscription is a third-party integration's registration to
+// receive event deliveries (see api/webhook) for a user's account: where
+// to POST them, what secret to sign them with, and which event types it
+// cares about.
+type WebhookSubscription struct {
+	ID        string      `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID    string      `gorm:"type:uuid;not null;index"`
+	URL       string      `gorm:"not null"`
+	Secret    string      `gorm:"not null"`
+	Events    StringArray `gorm:"type:text[]"`
+	Active    bool        `gorm:"default:true"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookDelivery logs a single attempt to deliver an event to a
+// WebhookSubscription, successful or not, so failed integrations can be
+// diagnosed without replaying the event.
+type WebhookDelivery struct {
+	ID             string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	SubscriptionID string `gorm:"type:uuid;not null;index"`
+	Event          string `gorm:"not null"`
+	Attempt        int    `gorm:"not null"`
+	StatusCode     int
+	Error          string
+	Delivered      bool `gorm:"default:false"`
+	CreatedAt      time.Time
+}
+
+// AuditRecord logs a single privileged, side-effecting admin action —
+// who did it, from where, and with what payload — independent of whatever
+// domain rows the action itself wrote.
+type AuditRecord struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Operator  string `gorm:"type:uuid;not null"`
+	Action    string `gorm:"not null"`
+	IP        string
+	UserAgent string
+	Payload   string `gorm:"type:text"`
+	CreatedAt time.Time
+}
+
+// AuthFactor is a credential a user can use to satisfy a login challenge
+// step: password, TOTP, email OTP, or a one-time backup code.
+type AuthFactor struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID    string `gorm:"type:uuid;not null"`
+	Type      string `gorm:"not null"` // password | totp | email_otp | backup_code
+	Secret    string `gorm:"type:text;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// AuthChallenge tracks an in-progress multi-step login negotiation.
+type AuthChallenge struct {
+	ID                 string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID             string `gorm:"type:uuid;not null"`
+	IP                 string
+	UserAgent          string
+	RemainingSteps     int         `gorm:"not null"`
+	BlacklistedFactors StringArray `gorm:"type:text[]"`
+	State              string      `gorm:"not null;default:pending"` // pending | completed | expired
+	ExpiresAt          time.Time
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// AuthEvent records a single challenge-verification attempt for auditing.
+type AuthEvent struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID    string `gorm:"type:uuid;not null"`
+	IP        string
+	UserAgent string
+	FactorID  string `gorm:"type:uuid"`
+	Outcome   string `gorm:"not null"` // success | failure
+	CreatedAt time.Time
+}
+
+type Story struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	UserID    string `gorm:"type:uuid;not null"`
+	Text      string
+	Image     string
+	Color     string
+	CreatedAt time.Time
 	UpdatedAt time.Time
 }
 
+func (s *Story) BeforeCreate(tx *gorm.DB) (err error) {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return
+}
+
+// StoryView records that ViewerID has seen StoryID, at most once per
+// pair (see the uniqueIndex), so a story's owner can see who's viewed
+// it and a viewer re-opening it doesn't duplicate the record.
+type StoryView struct {
+	ID       string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	StoryID  string `gorm:"type:uuid;not null;uniqueIndex:idx_story_views_story_viewer"`
+	ViewerID string `gorm:"type:uuid;not null;uniqueIndex:idx_story_views_story_viewer"`
+	ViewedAt time.Time
+}
+
+// StoryReaction records UserID's emoji reaction to StoryID. The
+// uniqueIndex means a user has at most one reaction per story; reacting
+// again with a different emoji replaces it rather than adding a second row.
+type StoryReaction struct {
+	ID        string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	StoryID   string `gorm:"type:uuid;not null;uniqueIndex:idx_story_reactions_story_user"`
+	UserID    string `gorm:"type:uuid;not null;uniqueIndex:idx_story_reactions_story_user"`
+	Emoji     string `gorm:"not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Product struct {
+	ID          string `gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Title       string
+	MobileIcon  string
+	DesktopIcon string
+	BrandIcon   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
 	if u.ID == "" {
 		u.ID = uuid.New().String()
@@ -208,9 +530,9 @@ func (m *Message) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
-func (f *FriendRequest) BeforeCreate(tx *gorm.DB) (err error) {
-	if f.ID == "" {
-		f.ID = uuid.New().String()
+func (r *RefreshToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
 	}
 	return
 }
@@ -222,6 +544,13 @@ func (n *Notification) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (a *AuditRecord) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return
+}
+
 func (p *Product) BeforeCreate(tx *gorm.DB) (err error) {
 	if p.ID == "" {
 		p.ID = uuid.New().String()
@@ -234,4 +563,88 @@ func (c *Comment) BeforeCreate(tx *gorm.DB) (err error) {
 		c.ID = uuid.New().String()
 	}
 	return
-}
\ No newline at end of file
+}
+
+func (r *RemoteUser) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (r *Relationship) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (a *Attachment) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return
+}
+
+func (f *AuthFactor) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return
+}
+
+func (c *AuthChallenge) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return
+}
+
+func (e *AuthEvent) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return
+}
+
+func (w *WebhookSubscription) BeforeCreate(tx *gorm.DB) (err error) {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return
+}
+
+func (d *DeviceToken) BeforeCreate(tx *gorm.DB) (err error) {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return
+}
+
+func (r *CallRecording) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return
+}
+
+func (c *Call) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return
+}
+
+func (p *CallParticipant) BeforeCreate(tx *gorm.DB) (err error) {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return
+}
@@ -0,0 +1,129 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"social-media-app/api/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// broadcastBatchSize bounds how many recipients are loaded and written per
+// round so a broadcast to the whole user base doesn't hold one giant
+// transaction or slice in memory.
+const broadcastBatchSize = 500
+
+// BroadcastRequest describes an admin-authored message to fan out to every
+// user. Metadata is recorded on the audit row but isn't persisted per
+// notification; Links is. IsForcePush is accepted for forward compatibility
+// with push delivery and currently only recorded in the audit trail.
+type BroadcastRequest struct {
+	Type        string                    `json:"type" validate:"required"`
+	Subject     string                    `json:"subject" validate:"required"`
+	Content     string                    `json:"content" validate:"required"`
+	Metadata    map[string]interface{}    `json:"metadata"`
+	Links       []models.NotificationLink `json:"links"`
+	IsForcePush bool                      `json:"isForcePush"`
+	IsRealtime  bool                      `json:"isRealtime"`
+}
+
+// Broadcast queues a notification for every user. Because the recipient
+// list can be the whole user base, the rows are written in the background
+// after the audit row is recorded; the response reports how many users
+// were queued rather than waiting for the fan-out to finish.
+func (h *NotificationHandler) Broadcast(c *fiber.Ctx) error {
+	operatorID := c.Locals("user_id").(string)
+
+	var req BroadcastRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.Type == "" || req.Subject == "" || req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "type, subject, and content are required"})
+	}
+
+	payloadJSON, _ := json.Marshal(req)
+	audit := models.AuditRecord{
+		Operator:  operatorID,
+		Action:    "notification.broadcast",
+		IP:        c.IP(),
+		UserAgent: c.Get("User-Agent"),
+		Payload:   string(payloadJSON),
+	}
+	if err := h.db.Create(&audit).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var recipientCount int64
+	if err := h.db.Model(&models.User{}).Count(&recipientCount).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	go h.runBroadcast(operatorID, req)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":    "Broadcast queued",
+		"auditId":    audit.ID,
+		"recipients": recipientCount,
+	})
+}
+
+// runBroadcast writes the broadcast notification for every user in batches
+// of broadcastBatchSize, publishing per-recipient when the caller asked for
+// isRealtime delivery.
+func (h *NotificationHandler) runBroadcast(operatorID string, req BroadcastRequest) {
+	ctx := context.Background()
+	links := models.NotificationLinks(req.Links)
+
+	var afterID string
+	for {
+		var userIDs []string
+		q := h.db.Model(&models.User{}).Order("id").Limit(broadcastBatchSize)
+		if afterID != "" {
+			q = q.Where("id > ?", afterID)
+		}
+		if err := q.Pluck("id", &userIDs).Error; err != nil {
+			log.Printf("notification: broadcast batch query failed: %v", err)
+			return
+		}
+		if len(userIDs) == 0 {
+			return
+		}
+
+		notifications := make([]models.Notification, len(userIDs))
+		for i, userID := range userIDs {
+			notifications[i] = models.Notification{
+				UserID:     userID,
+				Type:       req.Type,
+				FromUserID: operatorID,
+				Message:    req.Content,
+				Links:      links,
+			}
+		}
+		if err := h.db.Create(&notifications).Error; err != nil {
+			log.Printf("notification: broadcast batch insert failed: %v", err)
+			return
+		}
+
+		if req.IsRealtime {
+			for _, n := range notifications {
+				notificationJSON, _ := json.Marshal(fiber.Map{
+					"id":        n.ID,
+					"type":      n.Type,
+					"subject":   req.Subject,
+					"message":   n.Message,
+					"links":     n.Links,
+					"read":      false,
+					"createdAt": n.CreatedAt,
+				})
+				h.redisClient.Publish(ctx, "notification:"+n.UserID, notificationJSON)
+			}
+		}
+
+		afterID = userIDs[len(userIDs)-1]
+		if len(userIDs) < broadcastBatchSize {
+			return
+		}
+	}
+}
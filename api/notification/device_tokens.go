@@ -0,0 +1,43 @@
+package notification
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterDeviceTokenRequest registers or refreshes a mobile push target
+// for the current user.
+type RegisterDeviceTokenRequest struct {
+	Platform string `json:"platform" validate:"required"` // ios | android
+	Token    string `json:"token" validate:"required"`
+}
+
+// RegisterDeviceToken upserts a push token for the current user, so
+// services/push has somewhere to deliver an offline notification.
+func (h *NotificationHandler) RegisterDeviceToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req RegisterDeviceTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.Platform == "" || req.Token == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "platform and token are required"})
+	}
+
+	if err := h.push.RegisterToken(userID, req.Platform, req.Token); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Device token registered"})
+}
+
+// UnregisterDeviceToken removes :token from the current user's
+// registered devices, e.g. on logout or uninstall.
+func (h *NotificationHandler) UnregisterDeviceToken(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	token := c.Params("token")
+
+	if err := h.push.UnregisterToken(userID, token); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Device token unregistered"})
+}
@@ -0,0 +1,199 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"social-media-app/api/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	tickInterval = 30 * time.Second
+	dueBatch     = 100
+)
+
+// Batcher periodically sends every user whose digest is due a single
+// email summarizing their pending notifications, grouped by type.
+type Batcher struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	mailer      Mailer
+}
+
+func NewBatcher(db *gorm.DB, redisClient *redis.Client, mailer Mailer) *Batcher {
+	return &Batcher{db: db, redisClient: redisClient, mailer: mailer}
+}
+
+// Start ticks every tickInterval until ctx is cancelled.
+func (b *Batcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.tick(ctx)
+		}
+	}
+}
+
+// tick sends a digest to every user whose score in DueKey has passed.
+// Consuming a user's pending list clears their DueKey entry; the next
+// Enqueue call reschedules them one interval out, so a user with no new
+// activity simply falls off the due set instead of being re-armed with
+// nothing to send.
+func (b *Batcher) tick(ctx context.Context) {
+	userIDs, err := b.redisClient.ZRangeByScore(ctx, DueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(time.Now().Unix(), 10),
+		Count: dueBatch,
+	}).Result()
+	if err != nil {
+		log.Printf("digest: failed to read %s: %v", DueKey, err)
+		return
+	}
+
+	for _, userID := range userIDs {
+		b.sendDigest(ctx, userID)
+	}
+}
+
+func (b *Batcher) sendDigest(ctx context.Context, userID string) {
+	key := PendingKey(userID)
+	raw, err := b.redisClient.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		log.Printf("digest: failed to read %s: %v", key, err)
+		return
+	}
+	b.redisClient.Del(ctx, key)
+	b.redisClient.ZRem(ctx, DueKey, userID)
+
+	if len(raw) == 0 {
+		return
+	}
+
+	var events []Event
+	for _, item := range raw {
+		var event Event
+		if err := json.Unmarshal([]byte(item), &event); err != nil {
+			continue
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	var user models.User
+	if err := b.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		log.Printf("digest: failed to load user %s: %v", userID, err)
+		return
+	}
+	if !user.EmailEnabled || user.Email == "" {
+		return
+	}
+
+	lines := summarize(events)
+	subject := fmt.Sprintf("%d new notification(s)", len(events))
+	textBody := strings.Join(lines, "\n")
+	var html strings.Builder
+	html.WriteString("<ul>")
+	for _, line := range lines {
+		html.WriteString("<li>" + line + "</li>")
+	}
+	html.WriteString("</ul>")
+
+	if err := b.mailer.Send(user.Email, subject, textBody, html.String()); err != nil {
+		log.Printf("digest: failed to send to %s: %v", userID, err)
+	}
+}
+
+// group is the bucket key events are folded into: every reaction type
+// (like/love/haha/...) lands in the same "reacted to your X" bucket so
+// "3 people reacted" reads naturally instead of one line per emoji.
+type group struct {
+	kind      string
+	usernames []string
+	seen      map[string]bool
+}
+
+func kindFor(eventType string) string {
+	switch eventType {
+	case "comment", "comment_reply", "friend_request", "friend_accept":
+		return eventType
+	default:
+		if strings.HasPrefix(eventType, "comment_") {
+			return "reaction_comment"
+		}
+		return "reaction_post"
+	}
+}
+
+// summarize groups events by kind (preserving first-seen order) and
+// renders one human-readable line per kind.
+func summarize(events []Event) []string {
+	var order []string
+	groups := make(map[string]*group)
+	for _, event := range events {
+		kind := kindFor(event.Type)
+		g, ok := groups[kind]
+		if !ok {
+			g = &group{kind: kind, seen: make(map[string]bool)}
+			groups[kind] = g
+			order = append(order, kind)
+		}
+		if event.FromUsername != "" && !g.seen[event.FromUsername] {
+			g.seen[event.FromUsername] = true
+			g.usernames = append(g.usernames, event.FromUsername)
+		}
+	}
+
+	lines := make([]string, 0, len(order))
+	for _, kind := range order {
+		lines = append(lines, renderLine(groups[kind]))
+	}
+	return lines
+}
+
+func renderLine(g *group) string {
+	switch g.kind {
+	case "reaction_post":
+		return fmt.Sprintf("%d people reacted to your post", len(g.usernames))
+	case "reaction_comment":
+		return fmt.Sprintf("%d people reacted to your comment", len(g.usernames))
+	case "comment":
+		return namedSummary(g.usernames, "commented on your post")
+	case "comment_reply":
+		return namedSummary(g.usernames, "replied to your comment")
+	case "friend_request":
+		return namedSummary(g.usernames, "sent you a friend request")
+	case "friend_accept":
+		return namedSummary(g.usernames, "accepted your friend request")
+	default:
+		return namedSummary(g.usernames, "sent you a notification")
+	}
+}
+
+// namedSummary renders "Alice commented", "Alice and Bob commented", or
+// "Alice and 2 others commented" depending on how many distinct names
+// contributed to the group.
+func namedSummary(usernames []string, action string) string {
+	switch len(usernames) {
+	case 0:
+		return action
+	case 1:
+		return usernames[0] + " " + action
+	case 2:
+		return usernames[0] + " and " + usernames[1] + " " + action
+	default:
+		return usernames[0] + " and " + strconv.Itoa(len(usernames)-1) + " others " + action
+	}
+}
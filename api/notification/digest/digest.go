@@ -0,0 +1,110 @@
+// Package digest batches reaction/comment/follow notifications into a
+// single email per user instead of one per event. Handlers that create a
+// models.Notification also call Enqueue, which LPUSHes a compact Event
+// onto a per-user pending list and ZADDs the user into a "due" sorted set
+// scored by their next send time. A background Batcher (see batcher.go)
+// polls that set, groups the pending events, and emails a summary.
+//
+// Users on the "immediate" interval (the default) are skipped entirely:
+// their notifications keep going out over the existing Redis pub/sub
+// WebSocket path untouched, with no email at all.
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Interval is a user's chosen notification-digest cadence.
+type Interval string
+
+const (
+	Immediate Interval = "immediate"
+	Every15m  Interval = "15m"
+	Hourly    Interval = "1h"
+	Daily     Interval = "daily"
+	Off       Interval = "off"
+)
+
+// ValidIntervals is the set of intervals accepted by the
+// notification-prefs endpoint.
+var ValidIntervals = map[Interval]bool{
+	Immediate: true,
+	Every15m:  true,
+	Hourly:    true,
+	Daily:     true,
+	Off:       true,
+}
+
+func intervalDuration(interval Interval) time.Duration {
+	switch interval {
+	case Every15m:
+		return 15 * time.Minute
+	case Hourly:
+		return time.Hour
+	case Daily:
+		return 24 * time.Hour
+	default:
+		return 15 * time.Minute
+	}
+}
+
+// DueKey is the Redis sorted set of user ids with a pending digest,
+// scored by the Unix timestamp at which their next email is due.
+const DueKey = "digest:due"
+
+// PendingKey returns the Redis list of compact events awaiting delivery
+// in userID's next digest email.
+func PendingKey(userID string) string {
+	return "digest:pending:" + userID
+}
+
+// Event is the compact record LPUSHed onto a user's pending list. It
+// carries everything the Batcher needs to render a summary line without
+// re-querying Postgres for every event.
+type Event struct {
+	Type         string    `json:"type"`
+	FromUserID   string    `json:"fromUserId"`
+	FromUsername string    `json:"fromUsername"`
+	PostID       *string   `json:"postId,omitempty"`
+	CommentID    *string   `json:"commentId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Enqueue records event for userID's digest, unless userID has opted out
+// of email entirely or is on the "immediate" interval (already served by
+// the realtime WebSocket/pub-sub path). It schedules the user's next send
+// time only if they aren't already due, so an active digest window isn't
+// pushed back by later events.
+func Enqueue(ctx context.Context, db *gorm.DB, redisClient *redis.Client, userID string, event Event) error {
+	var user models.User
+	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return err
+	}
+
+	interval := Interval(user.DigestInterval)
+	if interval == "" {
+		interval = Immediate
+	}
+	if !user.EmailEnabled || interval == Off || interval == Immediate {
+		return nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := redisClient.LPush(ctx, PendingKey(userID), payload).Err(); err != nil {
+		return err
+	}
+
+	return redisClient.ZAddNX(ctx, DueKey, redis.Z{
+		Score:  float64(time.Now().Add(intervalDuration(interval)).Unix()),
+		Member: userID,
+	}).Err()
+}
@@ -0,0 +1,41 @@
+package digest
+
+import (
+	"fmt"
+	"net/smtp"
+	"social-media-app/config"
+)
+
+// Mailer sends a digest email. It's an interface so the Batcher can be
+// exercised without a real SMTP server.
+type Mailer interface {
+	Send(to, subject, textBody, htmlBody string) error
+}
+
+// SMTPMailer sends mail through the SMTP_* server configured in config.Config.
+type SMTPMailer struct {
+	cfg *config.Config
+}
+
+func NewSMTPMailer(cfg *config.Config) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send is a no-op when SMTP isn't configured (e.g. local dev), matching
+// auth.AuthHandler.sendEmailOTP.
+func (m *SMTPMailer) Send(to, subject, textBody, htmlBody string) error {
+	if m.cfg.SMTPHost == "" {
+		return nil
+	}
+
+	addr := m.cfg.SMTPHost + ":" + m.cfg.SMTPPort
+	auth := smtp.PlainAuth("", m.cfg.SMTPUsername, m.cfg.SMTPPassword, m.cfg.SMTPHost)
+	msg := []byte(fmt.Sprintf(
+		"Subject: %s\r\nContent-Type: multipart/alternative; boundary=digest\r\n\r\n"+
+			"--digest\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n"+
+			"--digest\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n"+
+			"--digest--\r\n",
+		subject, textBody, htmlBody,
+	))
+	return smtp.SendMail(addr, auth, m.cfg.SMTPFrom, []string{to}, msg)
+}
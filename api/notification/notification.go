@@ -3,10 +3,16 @@ package notification
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
 	"social-media-app/api/auth"
 	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"social-media-app/api/webhook"
 	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services/push"
+	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,24 +21,66 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	defaultTake = 20
+	maxTake     = 100
+
+	// listCacheTTL is short since a page's membership shifts with every new
+	// notification and every read; unlike the per-user caches elsewhere,
+	// exact invalidation isn't practical across the (take, offset, past)
+	// key space, so a short TTL bounds the staleness instead.
+	listCacheTTL = 15 * time.Second
+)
+
+// parseTake clamps a client-supplied take query param to [1, maxTake],
+// defaulting to defaultTake when raw is empty or not a positive integer.
+func parseTake(raw string) int {
+	if raw == "" {
+		return defaultTake
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return defaultTake
+	}
+	if n > maxTake {
+		return maxTake
+	}
+	return n
+}
+
+// parseOffset parses a client-supplied offset query param, defaulting to 0
+// when raw is empty, not an integer, or negative.
+func parseOffset(raw string) int {
+	if raw == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
 type NotificationHandler struct {
 	db          *gorm.DB
 	redisClient *redis.Client
+	push        *push.Service
 }
 
-func NewNotificationHandler(db *gorm.DB, redisClient *redis.Client) *NotificationHandler {
-	return &NotificationHandler{db, redisClient}
+func NewNotificationHandler(db *gorm.DB, redisClient *redis.Client, pushSvc *push.Service) *NotificationHandler {
+	return &NotificationHandler{db, redisClient, pushSvc}
 }
 
 // CreateNotification creates a new notification and sends it via WebSocket
 func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
 	var req struct {
-		ReceiverID string `json:"receiverId" validate:"required"`
-		Type       string `json:"type" validate:"required"`
-		Message    string `json:"message" validate:"required"`
-		PostID     string `json:"postId"`
-		CommentID  string `json:"commentId"`
+		ReceiverID  string `json:"receiverId" validate:"required"`
+		Type        string `json:"type" validate:"required"`
+		Message     string `json:"message" validate:"required"`
+		PostID      string `json:"postId"`
+		CommentID   string `json:"commentId"`
+		IsForcePush bool   `json:"isForcePush"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -60,23 +108,28 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	}
 
 	notification := models.Notification{
-		ID:         uuid.New().String(),
-		UserID:     req.ReceiverID,
-		Type:       req.Type,
-		FromUserID: userID,
-		PostID:     postID,
-		CommentID:  commentID,
-		Message:    req.Message,
-		Read:       false,
-		CreatedAt:  time.Now(),
+		ID:          uuid.New().String(),
+		UserID:      req.ReceiverID,
+		Type:        req.Type,
+		FromUserID:  userID,
+		PostID:      postID,
+		CommentID:   commentID,
+		Message:     req.Message,
+		Read:        false,
+		IsForcePush: req.IsForcePush,
+		CreatedAt:   time.Now(),
 	}
 
 	if err := h.db.Create(&notification).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Invalidate cache
-	h.redisClient.Del(context.Background(), "notifications:"+req.ReceiverID)
+	if err := digest.Enqueue(context.Background(), h.db, h.redisClient, req.ReceiverID, digest.Event{
+		Type: req.Type, FromUserID: userID, FromUsername: h.getUsername(userID),
+		PostID: postID, CommentID: commentID, CreatedAt: notification.CreatedAt,
+	}); err != nil {
+		log.Printf("notification: failed to enqueue digest event for %s: %v", req.ReceiverID, err)
+	}
 
 	// Prepare notification payload
 	notificationJSON, _ := json.Marshal(fiber.Map{
@@ -94,6 +147,17 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	// Publish to Redis for WebSocket
 	h.redisClient.Publish(context.Background(), "notification:"+req.ReceiverID, notificationJSON)
 
+	if err := webhook.Notify(context.Background(), h.db, h.redisClient, req.ReceiverID, webhook.EventNotificationCreated, notification); err != nil {
+		log.Printf("notification: failed to notify webhooks for %s: %v", req.ReceiverID, err)
+	}
+
+	if err := h.push.Send(context.Background(), req.ReceiverID, push.Payload{
+		Title: h.getUsername(userID),
+		Body:  notification.Message,
+	}, ws.IsOnline(req.ReceiverID), notification.IsForcePush); err != nil {
+		log.Printf("notification: failed to push to %s: %v", req.ReceiverID, err)
+	}
+
 	// Send via WebSocket
 	ws.SendNotification(req.ReceiverID, fiber.Map{
 		"id":           notification.ID,
@@ -110,22 +174,40 @@ func (h *NotificationHandler) CreateNotification(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Notification created", "notification": notification})
 }
 
-// GetNotifications retrieves all notifications for the current user
+// GetNotifications returns a page of the current user's notifications along
+// with the total matching count. By default only unread notifications are
+// returned; past=true includes already-read ones too. take and offset drive
+// pagination, both pushed into the DB query rather than loaded in full.
 func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
+	take := parseTake(c.Query("take"))
+	offset := parseOffset(c.Query("offset"))
+	past := c.Query("past") == "true"
 
-	// Check Redis cache first
-	cached, err := h.redisClient.Get(context.Background(), "notifications:"+userID).Result()
+	cacheKey := fmt.Sprintf("notifications:%s:%d:%d:%t", userID, take, offset, past)
+	cached, err := h.redisClient.Get(context.Background(), cacheKey).Result()
 	if err == nil {
-		var notifications []models.Notification
-		if err := json.Unmarshal([]byte(cached), &notifications); err == nil {
-			return c.JSON(notifications)
+		var page fiber.Map
+		if err := json.Unmarshal([]byte(cached), &page); err == nil {
+			return c.JSON(page)
 		}
 	}
 
-	// Fetch from database
+	filtered := func() *gorm.DB {
+		q := h.db.Model(&models.Notification{}).Where("user_id = ?", userID)
+		if !past {
+			q = q.Where("read = ?", false)
+		}
+		return q
+	}
+
+	var count int64
+	if err := filtered().Count(&count).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
 	var notifications []models.Notification
-	if err := h.db.Where("user_id = ?", userID).Order("created_at desc").Find(&notifications).Error; err != nil {
+	if err := filtered().Order("created_at desc").Limit(take).Offset(offset).Find(&notifications).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
@@ -145,9 +227,9 @@ func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
 		userMap[user.ID] = user.Username
 	}
 
-	response := []fiber.Map{}
+	data := []fiber.Map{}
 	for _, notification := range notifications {
-		response = append(response, fiber.Map{
+		data = append(data, fiber.Map{
 			"id":           notification.ID,
 			"type":         notification.Type,
 			"fromUserId":   notification.FromUserID,
@@ -160,11 +242,12 @@ func (h *NotificationHandler) GetNotifications(c *fiber.Ctx) error {
 		})
 	}
 
-	// Cache the result
-	notificationsJSON, _ := json.Marshal(response)
-	h.redisClient.Set(context.Background(), "notifications:"+userID, notificationsJSON, 3600)
+	page := fiber.Map{"count": count, "data": data}
+
+	pageJSON, _ := json.Marshal(page)
+	h.redisClient.Set(context.Background(), cacheKey, pageJSON, listCacheTTL)
 
-	return c.JSON(response)
+	return c.JSON(page)
 }
 
 // MarkNotificationAsRead marks a notification as read
@@ -177,14 +260,13 @@ func (h *NotificationHandler) MarkNotificationAsRead(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Notification not found"})
 	}
 
+	now := time.Now()
 	notification.Read = true
+	notification.ReadAt = &now
 	if err := h.db.Save(&notification).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	// Invalidate cache
-	h.redisClient.Del(context.Background(), "notifications:"+userID)
-
 	// Publish updated notification to Redis for WebSocket
 	notificationJSON, _ := json.Marshal(fiber.Map{
 		"id":           notification.ID,
@@ -215,6 +297,66 @@ func (h *NotificationHandler) MarkNotificationAsRead(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Notification marked as read"})
 }
 
+// MarkAllNotificationsAsRead stamps every unread notification for the
+// current user read in a single UPDATE, instead of one MarkNotificationAsRead
+// call per row.
+func (h *NotificationHandler) MarkAllNotificationsAsRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var ids []string
+	if err := h.db.Model(&models.Notification{}).Where("user_id = ? AND read = ?", userID, false).Pluck("id", &ids).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if len(ids) == 0 {
+		return c.JSON(fiber.Map{"message": "No unread notifications", "ids": []string{}})
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&models.Notification{}).Where("id IN ?", ids).
+		Updates(map[string]interface{}{"read": true, "read_at": now}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	h.publishBulkRead(userID, ids)
+	return c.JSON(fiber.Map{"message": "Notifications marked as read", "ids": ids})
+}
+
+// MarkNotificationsAsRead stamps a caller-supplied batch of notification IDs
+// read in a single UPDATE, scoped to the caller so one user can't mark
+// another's notifications read.
+func (h *NotificationHandler) MarkNotificationsAsRead(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req struct {
+		IDs []string `json:"ids" validate:"required"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if len(req.IDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "ids is required"})
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&models.Notification{}).Where("id IN ? AND user_id = ?", req.IDs, userID).
+		Updates(map[string]interface{}{"read": true, "read_at": now}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	h.publishBulkRead(userID, req.IDs)
+	return c.JSON(fiber.Map{"message": "Notifications marked as read", "ids": req.IDs})
+}
+
+// publishBulkRead notifies a user's connected clients that a batch of
+// notification IDs just transitioned to read, in a single event rather than
+// one per row.
+func (h *NotificationHandler) publishBulkRead(userID string, ids []string) {
+	payload := fiber.Map{"event": "notifications_read", "ids": ids, "readAt": time.Now()}
+	payloadJSON, _ := json.Marshal(payload)
+	h.redisClient.Publish(context.Background(), "notification:"+userID, payloadJSON)
+	ws.SendNotification(userID, payload)
+}
+
 // getUsername fetches the username for a given user ID
 func (h *NotificationHandler) getUsername(userID string) string {
 	var user models.User
@@ -225,14 +367,19 @@ func (h *NotificationHandler) getUsername(userID string) string {
 }
 
 // Setup configures the notification routes
-func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewNotificationHandler(db, redisClient)
+func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client, pushSvc *push.Service) {
+	handler := NewNotificationHandler(db, redisClient, pushSvc)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
 	notification := api.Group("/notification")
-	notification.Get("/", auth.JWTMiddleware(cfg), handler.GetNotifications)
-	notification.Put("/:id/read", auth.JWTMiddleware(cfg), handler.MarkNotificationAsRead)
-	notification.Post("/", auth.JWTMiddleware(cfg), handler.CreateNotification)
-}
\ No newline at end of file
+	notification.Get("/", auth.JWTMiddleware(cfg, redisClient), handler.GetNotifications)
+	notification.Put("/read-all", auth.JWTMiddleware(cfg, redisClient), handler.MarkAllNotificationsAsRead)
+	notification.Put("/read", auth.JWTMiddleware(cfg, redisClient), handler.MarkNotificationsAsRead)
+	notification.Put("/:id/read", auth.JWTMiddleware(cfg, redisClient), handler.MarkNotificationAsRead)
+	notification.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreateNotification)
+	notification.Post("/broadcast", auth.JWTMiddleware(cfg, redisClient), auth.RequireAdmin(db), handler.Broadcast)
+	notification.Post("/device-tokens", auth.JWTMiddleware(cfg, redisClient), handler.RegisterDeviceToken)
+	notification.Delete("/device-tokens/:token", auth.JWTMiddleware(cfg, redisClient), handler.UnregisterDeviceToken)
+}
@@ -0,0 +1,74 @@
+// Package pagination implements keyset (cursor) pagination shared by the
+// endpoints that list rows ordered by (created_at, id): the user
+// directory, follower/following lists, chats, and post feeds.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor identifies a position in a (created_at, id) ordered result set.
+type Cursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        string    `json:"id"`
+}
+
+// Encode returns an opaque base64 token for a cursor.
+func Encode(createdAt time.Time, id string) string {
+	raw, _ := json.Marshal(Cursor{CreatedAt: createdAt, ID: id})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// Decode parses a cursor token produced by Encode. An empty token decodes
+// to a nil cursor, meaning "start from the first page".
+func Decode(token string) (*Cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var cur Cursor
+	if err := json.Unmarshal(raw, &cur); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &cur, nil
+}
+
+// ParseLimit clamps a client-supplied limit query param to [1, MaxLimit],
+// defaulting to DefaultLimit when raw is empty or not a positive integer.
+func ParseLimit(raw string) int {
+	if raw == "" {
+		return DefaultLimit
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return DefaultLimit
+	}
+	if n > MaxLimit {
+		return MaxLimit
+	}
+	return n
+}
+
+// Apply adds a keyset WHERE clause, descending (created_at, id) ordering,
+// and a row limit to q. Row-value comparison keeps the cursor stable even
+// as new rows are inserted between page fetches.
+func Apply(q *gorm.DB, cursor *Cursor, limit int) *gorm.DB {
+	if cursor != nil {
+		q = q.Where("(created_at, id) < (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	return q.Order("created_at DESC, id DESC").Limit(limit)
+}
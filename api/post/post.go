@@ -3,18 +3,36 @@ package post
 import (
 	"context"
 	"encoding/json"
+	"log"
+	"social-media-app/api/activitypub"
 	"social-media-app/api/auth"
+	"social-media-app/api/ephemeral"
+	"social-media-app/api/feed"
 	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"social-media-app/api/upload"
 	"social-media-app/config"
+	"social-media-app/services"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
+// signedImageURLTTL governs how long a post's cached signed image URL
+// stays in Redis; it is kept shorter than upload.signedURLTTL so callers
+// never hand out a link whose underlying signature has already expired.
+const signedImageURLTTL = 10 * time.Minute
+
 type CreatePostRequest struct {
-	UserID string `json:"userId" validate:"required"`
-	Desc   string `json:"desc"`
-	Image  string `json:"image"`
+	UserID           string `json:"userId" validate:"required"`
+	Desc             string `json:"desc"`
+	Image            string `json:"image"`
+	ImageKey         string `json:"imageKey"`
+	ExpiresInSeconds int64  `json:"expiresInSeconds"`
+	BurnAfterReading bool   `json:"burnAfterReading"`
 }
 
 type UpdatePostRequest struct {
@@ -23,12 +41,16 @@ type UpdatePostRequest struct {
 }
 
 type PostHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
+	db            *gorm.DB
+	redisClient   *redis.Client
+	relationships *services.RelationshipService
+	ap            *activitypub.Handler
+	storage       upload.Storage
+	fanout        *feed.Fanout
 }
 
-func NewPostHandler(db *gorm.DB, redisClient *redis.Client) *PostHandler {
-	return &PostHandler{db, redisClient}
+func NewPostHandler(db *gorm.DB, redisClient *redis.Client, relationships *services.RelationshipService, ap *activitypub.Handler, storage upload.Storage, fanout *feed.Fanout) *PostHandler {
+	return &PostHandler{db, redisClient, relationships, ap, storage, fanout}
 }
 
 func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
@@ -50,38 +72,112 @@ func (h *PostHandler) CreatePost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to verify user: " + err.Error()})
 	}
 
+	image := req.Image
+	if req.ImageKey != "" {
+		if _, _, err := h.storage.Head(context.Background(), req.ImageKey); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Image upload not found, it may not have finished uploading"})
+		}
+		image = req.ImageKey
+	}
+
 	post := models.Post{
-		UserID: req.UserID,
-		Desc:   req.Desc,
-		Image:  req.Image,
+		UserID:        req.UserID,
+		Desc:          req.Desc,
+		Image:         image,
+		BurnAfterRead: req.BurnAfterReading,
+	}
+	if req.ExpiresInSeconds > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInSeconds) * time.Second)
+		post.ExpiresAt = &expiresAt
 	}
 
 	if err := h.db.Create(&post).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create post: " + err.Error()})
 	}
 
+	if post.ExpiresAt != nil {
+		if err := ephemeral.Schedule(h.redisClient, ephemeral.PostsExpiryKey, post.ID, *post.ExpiresAt); err != nil {
+			log.Printf("post: failed to schedule expiry for %s: %v", post.ID, err)
+		}
+	}
+
 	postJSON, _ := json.Marshal(post)
 	h.redisClient.Set(context.Background(), "post:"+post.ID, postJSON, 3600)
+
+	go func() {
+		if err := h.fanout.OnCreate(context.Background(), post); err != nil {
+			log.Printf("post: failed to fan out %s to followers' feeds: %v", post.ID, err)
+		}
+	}()
+	go h.ap.DeliverCreateNote(&user, &post)
+
 	return c.JSON(post)
 }
 
 func (h *PostHandler) GetPost(c *fiber.Ctx) error {
 	postID := c.Params("id")
+	var post models.Post
 	cached, err := h.redisClient.Get(context.Background(), "post:"+postID).Result()
 	if err == nil {
-		var post models.Post
 		json.Unmarshal([]byte(cached), &post)
-		return c.JSON(post)
+	} else {
+		if err := h.db.Where("id = ?", postID).First(&post).Error; err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Post not found"})
+		}
+		postJSON, _ := json.Marshal(post)
+		h.redisClient.Set(context.Background(), "post:"+postID, postJSON, 3600)
 	}
 
-	var post models.Post
-	if err := h.db.Where("id = ?", postID).First(&post).Error; err != nil {
+	if post.ExpiresAt != nil && post.ExpiresAt.Before(time.Now()) {
+		h.redisClient.Del(context.Background(), "post:"+postID, "post:"+postID+":url")
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Post not found"})
 	}
 
-	postJSON, _ := json.Marshal(post)
-	h.redisClient.Set(context.Background(), "post:"+postID, postJSON, 3600)
-	return c.JSON(post)
+	if post.BurnAfterRead && c.Query("viewerId") != post.UserID {
+		if err := ephemeral.Schedule(h.redisClient, ephemeral.PostsExpiryKey, post.ID, time.Now()); err != nil {
+			log.Printf("post: failed to schedule burn-after-read expiry for %s: %v", post.ID, err)
+		}
+	}
+
+	imageURL, err := h.resolveImageURL(post)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	response, _ := json.Marshal(post)
+	var out map[string]interface{}
+	json.Unmarshal(response, &out)
+	if imageURL != "" {
+		out["imageUrl"] = imageURL
+	}
+	return c.JSON(out)
+}
+
+// resolveImageURL turns post.Image into a URL the client can load. Legacy
+// posts already store a full URL (static /images mount or an old signed
+// link); newer posts store a bare object key, so it needs signing on
+// every read. The signed URL is cached in Redis under a TTL shorter than
+// the signature itself so GetPost never hands out a link that expires
+// before the cache does.
+func (h *PostHandler) resolveImageURL(post models.Post) (string, error) {
+	if post.Image == "" {
+		return "", nil
+	}
+	if strings.Contains(post.Image, "://") {
+		return post.Image, nil
+	}
+
+	cacheKey := "post:" + post.ID + ":url"
+	if cached, err := h.redisClient.Get(context.Background(), cacheKey).Result(); err == nil {
+		return cached, nil
+	}
+
+	url, err := h.storage.SignedURL(context.Background(), post.Image, upload.SignedURLTTL)
+	if err != nil {
+		return "", err
+	}
+	h.redisClient.Set(context.Background(), cacheKey, url, signedImageURLTTL)
+	return url, nil
 }
 
 func (h *PostHandler) UpdatePost(c *fiber.Ctx) error {
@@ -131,7 +227,19 @@ func (h *PostHandler) DeletePost(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	h.redisClient.Del(context.Background(), "post:"+postID)
+	h.redisClient.Del(context.Background(), "post:"+postID, "post:"+postID+":url")
+
+	go func() {
+		if err := h.fanout.OnDelete(context.Background(), post); err != nil {
+			log.Printf("post: failed to remove %s from followers' feeds: %v", post.ID, err)
+		}
+	}()
+
+	var owner models.User
+	if err := h.db.Where("id = ?", post.UserID).First(&owner).Error; err == nil {
+		go h.ap.DeliverDelete(&owner, &post)
+	}
+
 	return c.JSON(fiber.Map{"message": "Post deleted"})
 }
 
@@ -196,6 +304,9 @@ func (h *PostHandler) LikePost(c *fiber.Ctx) error {
 	var liker models.User
 	var notification models.Notification
 	if err := h.db.Where("id = ?", userID).First(&liker).Error; err == nil && req.ReactionType != "" {
+		if req.ReactionType == "like" && currentReaction != "like" {
+			go h.ap.DeliverLike(&liker, &post)
+		}
 		if post.UserID != userID {
 			notification = models.Notification{
 				UserID:     post.UserID,
@@ -206,6 +317,12 @@ func (h *PostHandler) LikePost(c *fiber.Ctx) error {
 				Read:       false,
 			}
 			h.db.Create(&notification)
+			if err := digest.Enqueue(context.Background(), h.db, h.redisClient, post.UserID, digest.Event{
+				Type: req.ReactionType, FromUserID: userID, FromUsername: liker.Username,
+				PostID: &post.ID, CreatedAt: notification.CreatedAt,
+			}); err != nil {
+				log.Printf("post: failed to enqueue digest event for %s: %v", post.UserID, err)
+			}
 		}
 	}
 
@@ -231,49 +348,24 @@ func removeUser(users []string, userID string) []string {
 	return users
 }
 
-func (h *PostHandler) GetTimelinePosts(c *fiber.Ctx) error {
-	userID := c.Params("id")
-	cached, err := h.redisClient.Get(context.Background(), "timeline:"+userID).Result()
-	if err == nil {
-		var posts []models.Post
-		if err := json.Unmarshal([]byte(cached), &posts); err == nil {
-			return c.JSON(posts)
-		}
-	}
-
-	var user models.User
-	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
-	}
-
-	var posts []models.Post
-	if len(user.Following) == 0 {
-		if err := h.db.Where("user_id = ?", userID).Find(&posts).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-		}
-	} else {
-		followingIDs := []string(user.Following)
-		if err := h.db.Where("user_id = ? OR user_id IN ?", userID, followingIDs).Find(&posts).Error; err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-		}
-	}
-
-	postJSON, _ := json.Marshal(posts)
-	h.redisClient.Set(context.Background(), "timeline:"+userID, postJSON, 3600)
-	return c.JSON(posts)
-}
-
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewPostHandler(db, redisClient)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
+	relationships := services.NewRelationshipService(db, redisClient)
+	ap := activitypub.NewHandler(db, redisClient, cfg)
+	storage, err := upload.NewStorage(cfg)
+	if err != nil {
+		panic("Failed to initialize storage backend: " + err.Error())
+	}
+	fanout := feed.NewFanout(redisClient, relationships)
+	handler := NewPostHandler(db, redisClient, relationships, ap, storage, fanout)
 	post := api.Group("/post")
-	post.Post("/", auth.JWTMiddleware(cfg), handler.CreatePost)
+	post.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreatePost)
 	post.Get("/:id", handler.GetPost)
-	post.Put("/:id", auth.JWTMiddleware(cfg), handler.UpdatePost)
-	post.Delete("/:id", auth.JWTMiddleware(cfg), handler.DeletePost)
-	post.Put("/:id/like", auth.JWTMiddleware(cfg), handler.LikePost)
+	post.Put("/:id", auth.JWTMiddleware(cfg, redisClient), handler.UpdatePost)
+	post.Delete("/:id", auth.JWTMiddleware(cfg, redisClient), handler.DeletePost)
+	post.Put("/:id/like", auth.JWTMiddleware(cfg, redisClient), handler.LikePost)
 	post.Get("/:id/timeline", handler.GetTimelinePosts)
-}
\ No newline at end of file
+}
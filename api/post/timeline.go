@@ -0,0 +1,177 @@
+package post
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"social-media-app/api/feed"
+	"social-media-app/api/models"
+	"social-media-app/api/pagination"
+	"social-media-app/services"
+	"sort"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// GetTimelinePosts returns userID's home timeline: their own posts plus
+// those of the accounts they actively follow, newest first, with cursor
+// pagination. Rather than joining across every followed account on each
+// request, it reads feed.Key(userID), the Redis sorted set Fanout.OnCreate
+// populates on every post published by someone userID follows, and
+// hydrates the resulting ids from the post:{id} cache (falling back to
+// Postgres and backfilling on a miss). Authors over
+// feed.CelebrityFollowerThreshold skip push fan-out, so their recent
+// posts are merged in here at read time instead.
+func (h *PostHandler) GetTimelinePosts(c *fiber.Ctx) error {
+	userID := c.Params("id")
+	limit := pagination.ParseLimit(c.Query("limit"))
+	cursor, err := pagination.Decode(c.Query("cursor"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	ctx := context.Background()
+	max := "+inf"
+	if cursor != nil {
+		max = fmt.Sprintf("(%d", cursor.CreatedAt.UnixNano())
+	}
+	ids, err := h.redisClient.ZRevRangeByScore(ctx, feed.Key(userID), &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   max,
+		Count: int64(limit + 1),
+	}).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	posts, err := h.hydratePosts(ctx, ids)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	following, err := h.relationships.List(userID, services.RelationshipActive)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	if len(following) > 0 {
+		followingIDs := make([]string, len(following))
+		for i, rel := range following {
+			followingIDs[i] = rel.RelatedID
+		}
+		hotIDs, err := h.fanout.FilterHot(ctx, followingIDs)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+		if len(hotIDs) > 0 {
+			q := h.db.Where("user_id IN ? AND (expires_at IS NULL OR expires_at > ?)", hotIDs, time.Now())
+			if cursor != nil {
+				q = q.Where("created_at < ?", cursor.CreatedAt)
+			}
+			var hotPosts []models.Post
+			if err := q.Order("created_at DESC").Limit(limit + 1).Find(&hotPosts).Error; err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+			}
+			posts = mergePosts(posts, hotPosts, limit+1)
+		}
+	}
+
+	var nextCursor string
+	if len(posts) > limit {
+		last := posts[limit-1]
+		nextCursor = pagination.Encode(last.CreatedAt, last.ID)
+		posts = posts[:limit]
+	}
+
+	return c.JSON(fiber.Map{"data": posts, "next_cursor": nextCursor})
+}
+
+// hydratePosts resolves feed post ids to full rows via the post:{id}
+// cache, batch-fetching any misses from Postgres and backfilling the
+// cache, then re-orders the result to match ids (the feed's recency
+// order) and drops anything that has since expired.
+func (h *PostHandler) hydratePosts(ctx context.Context, ids []string) ([]models.Post, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = "post:" + id
+	}
+	cached, err := h.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]models.Post, 0, len(ids))
+	var missingIDs []string
+	for i, v := range cached {
+		raw, ok := v.(string)
+		var p models.Post
+		if !ok || json.Unmarshal([]byte(raw), &p) != nil {
+			missingIDs = append(missingIDs, ids[i])
+			continue
+		}
+		posts = append(posts, p)
+	}
+
+	if len(missingIDs) > 0 {
+		var dbPosts []models.Post
+		if err := h.db.Where("id IN ?", missingIDs).Find(&dbPosts).Error; err != nil {
+			return nil, err
+		}
+		for _, p := range dbPosts {
+			posts = append(posts, p)
+			postJSON, _ := json.Marshal(p)
+			h.redisClient.Set(ctx, "post:"+p.ID, postJSON, 3600)
+		}
+	}
+
+	order := make(map[string]int, len(ids))
+	for i, id := range ids {
+		order[id] = i
+	}
+	sort.Slice(posts, func(i, j int) bool { return order[posts[i].ID] < order[posts[j].ID] })
+
+	now := time.Now()
+	fresh := posts[:0]
+	for _, p := range posts {
+		if p.ExpiresAt != nil && p.ExpiresAt.Before(now) {
+			continue
+		}
+		fresh = append(fresh, p)
+	}
+	return fresh, nil
+}
+
+// mergePosts combines two slices already sorted newest-first by
+// CreatedAt, dedupes by id, and returns at most limit posts.
+func mergePosts(a, b []models.Post, limit int) []models.Post {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]models.Post, 0, len(a)+len(b))
+	for _, p := range a {
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			merged = append(merged, p)
+		}
+	}
+	for _, p := range b {
+		if !seen[p.ID] {
+			seen[p.ID] = true
+			merged = append(merged, p)
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].CreatedAt.After(merged[j].CreatedAt) })
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
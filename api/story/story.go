@@ -2,17 +2,23 @@ package story
 
 import (
 	"context"
-	"encoding/json"
+	"log"
 	"social-media-app/api/auth"
-	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
 	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
-	"time"
 )
 
+// storyExpiryInterval is how often Setup's background worker checks for
+// stories older than 24 hours to delete.
+const storyExpiryInterval = 10 * time.Minute
+
 type CreateStoryRequest struct {
 	UserID string `json:"userId" validate:"required"`
 	Text   string `json:"text"`
@@ -20,13 +26,25 @@ type CreateStoryRequest struct {
 	Color  string `json:"color" validate:"required"` // Background color for text
 }
 
+type ReactToStoryRequest struct {
+	Emoji string `json:"emoji" validate:"required"`
+}
+
 type StoryHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
+	stories *services.StoryService
 }
 
-func NewStoryHandler(db *gorm.DB, redisClient *redis.Client) *StoryHandler {
-	return &StoryHandler{db, redisClient}
+func NewStoryHandler(stories *services.StoryService) *StoryHandler {
+	return &StoryHandler{stories}
+}
+
+// respondAppError maps a *services.AppError to its HTTP response. If
+// err isn't an AppError, it's treated as an unexpected internal error.
+func respondAppError(c *fiber.Ctx, err error) error {
+	if appErr, ok := err.(*services.AppError); ok {
+		return c.Status(appErr.StatusCode).JSON(fiber.Map{"message": appErr.Message})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 }
 
 func (h *StoryHandler) CreateStory(c *fiber.Ctx) error {
@@ -40,28 +58,11 @@ func (h *StoryHandler) CreateStory(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot create story for another user"})
 	}
 
-	var user models.User
-	if err := h.db.Where("id = ?", req.UserID).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
-	}
-
-	story := models.Story{
-		UserID: req.UserID,
-		Text:   req.Text,
-		Image:  req.Image,
-		Color:  req.Color,
-	}
-
-	if err := h.db.Create(&story).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to create story: " + err.Error()})
+	story, followers, err := h.stories.CreateStory(req.UserID, req.Text, req.Image, req.Color)
+	if err != nil {
+		return respondAppError(c, err)
 	}
 
-	// Cache the story
-	storyJSON, _ := json.Marshal(story)
-	h.redisClient.Set(context.Background(), "story:"+story.ID, storyJSON, 24*time.Hour)
-
-	// Notify followers
-	followers := user.Followers
 	storyMap := map[string]interface{}{
 		"id":        story.ID,
 		"userId":    story.UserID,
@@ -79,30 +80,93 @@ func (h *StoryHandler) CreateStory(c *fiber.Ctx) error {
 func (h *StoryHandler) GetStories(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
 
-	var user models.User
-	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	stories, err := h.stories.GetVisibleStories(userID)
+	if err != nil {
+		return respondAppError(c, err)
+	}
+	return c.JSON(stories)
+}
+
+// ViewStory records that the calling user has seen :id.
+func (h *StoryHandler) ViewStory(c *fiber.Ctx) error {
+	storyID := c.Params("id")
+	userID := c.Locals("user_id").(string)
+
+	if err := h.stories.ViewStory(storyID, userID); err != nil {
+		return respondAppError(c, err)
 	}
+	return c.JSON(fiber.Map{"message": "View recorded"})
+}
 
-	followingIDs := []string(user.Following)
-	followingIDs = append(followingIDs, userID) // Include own stories
+// GetStoryViewers lists who's seen :id. Restricted to the story's owner.
+func (h *StoryHandler) GetStoryViewers(c *fiber.Ctx) error {
+	storyID := c.Params("id")
+	userID := c.Locals("user_id").(string)
 
-	var stories []models.Story
-	now := time.Now().Add(-24 * time.Hour)
-	if err := h.db.Where("user_id IN ? AND created_at > ?", followingIDs, now).Find(&stories).Error; err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	views, err := h.stories.GetViewers(storyID, userID)
+	if err != nil {
+		return respondAppError(c, err)
 	}
+	return c.JSON(views)
+}
 
-	return c.JSON(stories)
+// ReactToStory records the calling user's emoji reaction to :id.
+func (h *StoryHandler) ReactToStory(c *fiber.Ctx) error {
+	storyID := c.Params("id")
+	userID := c.Locals("user_id").(string)
+
+	var req ReactToStoryRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	reaction, err := h.stories.ReactToStory(storyID, userID, req.Emoji)
+	if err != nil {
+		return respondAppError(c, err)
+	}
+	return c.JSON(reaction)
+}
+
+// runExpiryWorker periodically deletes stories older than 24 hours and
+// tells their owners' followers to drop them, so GetStories' 24-hour
+// window isn't the only thing keeping expired stories out of view.
+func runExpiryWorker(stories *services.StoryService) {
+	ticker := time.NewTicker(storyExpiryInterval)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			expired, err := stories.ExpireStories(context.Background())
+			if err != nil {
+				log.Printf("story: failed to expire stories: %v", err)
+				continue
+			}
+			for _, story := range expired {
+				ws.SendStoryExpired(story.Followers, story.StoryID)
+			}
+		}
+	}()
 }
 
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewStoryHandler(db, redisClient)
+	enqueueDigest := func(ctx context.Context, userID string, event services.DigestEvent) error {
+		return digest.Enqueue(ctx, db, redisClient, userID, digest.Event{
+			Type: event.Type, FromUserID: event.FromUserID, FromUsername: event.FromUsername,
+			PostID: event.PostID, CommentID: event.CommentID, CreatedAt: event.CreatedAt,
+		})
+	}
+	notifications := services.NewNotificationService(db, redisClient, enqueueDigest)
+	stories := services.NewStoryService(db, redisClient, notifications)
+	handler := NewStoryHandler(stories)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
 	story := api.Group("/story")
-	story.Post("/", auth.JWTMiddleware(cfg), handler.CreateStory)
-	story.Get("/", auth.JWTMiddleware(cfg), handler.GetStories)
-}
\ No newline at end of file
+	story.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreateStory)
+	story.Get("/", auth.JWTMiddleware(cfg, redisClient), handler.GetStories)
+	story.Post("/:id/view", auth.JWTMiddleware(cfg, redisClient), handler.ViewStory)
+	story.Get("/:id/viewers", auth.JWTMiddleware(cfg, redisClient), handler.GetStoryViewers)
+	story.Post("/:id/react", auth.JWTMiddleware(cfg, redisClient), handler.ReactToStory)
+
+	runExpiryWorker(stories)
+}
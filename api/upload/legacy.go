@@ -0,0 +1,52 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"social-media-app/config"
+	"time"
+)
+
+// legacyStorage preserves the pre-Storage-interface behavior: files are
+// written to public/images and served back by the plain, unsigned
+// /images static mount in main.go. It exists purely as a migration path
+// for deployments with existing links baked into that public URL shape;
+// new deployments should pick "local", "s3", or "supabase" instead,
+// which sign their GET (and now PUT) URLs.
+type legacyStorage struct {
+	baseDir string
+	baseURL string
+}
+
+func newLegacyStorage(cfg *config.Config) *legacyStorage {
+	return &legacyStorage{baseDir: "public/images", baseURL: cfg.BaseURL}
+}
+
+func (s *legacyStorage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	dst := filepath.Join(s.baseDir, key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		return "", err
+	}
+	return "legacy", nil
+}
+
+func (s *legacyStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return s.baseURL + "/images/" + key, nil
+}
+
+func (s *legacyStorage) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	return "", fmt.Errorf("upload: the legacy storage driver does not support presigned uploads, switch STORAGE_BACKEND to local/s3/supabase")
+}
+
+func (s *legacyStorage) Head(ctx context.Context, key string) (int64, string, error) {
+	info, err := os.Stat(filepath.Join(s.baseDir, key))
+	if err != nil {
+		return 0, "", err
+	}
+	return info.Size(), "", nil
+}
@@ -0,0 +1,93 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"social-media-app/config"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// s3Storage stores objects in an S3-compatible bucket and vends
+// presigned GET URLs for reading them back.
+type s3Storage struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+func newS3Storage(cfg *config.Config) (*s3Storage, error) {
+	if cfg.S3Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required for the s3 storage backend")
+	}
+
+	awsCfg := aws.Config{
+		Region:      cfg.S3Region,
+		Credentials: credentials.NewStaticCredentialsProvider(cfg.S3AccessKeyID, cfg.S3SecretAccessKey, ""),
+	}
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.S3Endpoint != "" {
+			endpoint := cfg.S3Endpoint
+			o.EndpointResolver = s3.EndpointResolverFunc(func(region string, options s3.EndpointResolverOptions) (aws.Endpoint, error) {
+				return aws.Endpoint{URL: endpoint}, nil
+			})
+		}
+	})
+
+	return &s3Storage{client: client, presign: s3.NewPresignClient(client), bucket: cfg.S3Bucket}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return s.bucket, nil
+}
+
+func (s *s3Storage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *s3Storage) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignPutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: aws.String(contentType),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *s3Storage) Head(ctx context.Context, key string) (int64, string, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	contentType := ""
+	if out.ContentType != nil {
+		contentType = *out.ContentType
+	}
+	return out.ContentLength, contentType, nil
+}
@@ -0,0 +1,44 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"social-media-app/config"
+	"time"
+)
+
+// Storage is a pluggable backend for storing uploaded objects and
+// producing short-lived signed URLs to read and write them directly.
+type Storage interface {
+	// Put writes data under key and returns the bucket it was stored in.
+	Put(ctx context.Context, key string, data []byte, contentType string) (bucket string, err error)
+	// SignedURL returns a GET URL for key that expires after ttl.
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// PresignPut returns a PUT URL for key that expires after ttl, so a
+	// client can upload directly to the backend without proxying bytes
+	// through this server.
+	PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error)
+	// Head reports the size and content type of an already-stored
+	// object, erroring if it doesn't exist yet (e.g. the client hasn't
+	// finished a presigned PUT).
+	Head(ctx context.Context, key string) (size int64, contentType string, err error)
+}
+
+// NewStorage builds the Storage backend selected by cfg.StorageBackend,
+// defaulting to local disk when unset. "legacy" keeps serving uploads
+// from the plain, unsigned /images static mount for deployments that
+// haven't migrated existing links to a real backend yet.
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageBackend {
+	case "s3":
+		return newS3Storage(cfg)
+	case "supabase":
+		return newSupabaseStorage(cfg)
+	case "legacy":
+		return newLegacyStorage(cfg), nil
+	case "", "local":
+		return newLocalStorage(cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.StorageBackend)
+	}
+}
@@ -0,0 +1,136 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"social-media-app/config"
+	"time"
+)
+
+// supabaseStorage stores objects via the Supabase Storage REST API,
+// reusing the project's existing Supabase credentials.
+type supabaseStorage struct {
+	baseURL string
+	apiKey  string
+	bucket  string
+}
+
+func newSupabaseStorage(cfg *config.Config) (*supabaseStorage, error) {
+	if cfg.SupabaseBucket == "" {
+		return nil, fmt.Errorf("SUPABASE_BUCKET is required for the supabase storage backend")
+	}
+	return &supabaseStorage{baseURL: cfg.SupabaseURL, apiKey: cfg.SupabaseAnonKey, bucket: cfg.SupabaseBucket}, nil
+}
+
+func (s *supabaseStorage) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/%s/%s", s.baseURL, s.bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("apikey", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("supabase storage upload failed: status %d", resp.StatusCode)
+	}
+	return s.bucket, nil
+}
+
+func (s *supabaseStorage) SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/sign/%s/%s", s.baseURL, s.bucket, key)
+	body, _ := json.Marshal(map[string]int{"expiresIn": int(ttl.Seconds())})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("supabase storage sign failed: status %d", resp.StatusCode)
+	}
+
+	var signed struct {
+		SignedURL string `json:"signedURL"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/storage/v1" + signed.SignedURL, nil
+}
+
+// PresignPut asks Supabase Storage for a signed upload URL, so the
+// client can PUT the object directly instead of proxying through Upload.
+func (s *supabaseStorage) PresignPut(ctx context.Context, key, contentType string, ttl time.Duration) (string, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/upload/sign/%s/%s", s.baseURL, s.bucket, key)
+	body, _ := json.Marshal(map[string]int{"expiresIn": int(ttl.Seconds())})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apikey", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("supabase storage presign failed: status %d", resp.StatusCode)
+	}
+
+	var signed struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return "", err
+	}
+	return s.baseURL + "/storage/v1" + signed.URL, nil
+}
+
+// Head fetches object metadata via the Supabase Storage info endpoint.
+func (s *supabaseStorage) Head(ctx context.Context, key string) (int64, string, error) {
+	url := fmt.Sprintf("%s/storage/v1/object/info/%s/%s", s.baseURL, s.bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("apikey", s.apiKey)
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, "", fmt.Errorf("supabase storage info failed: status %d", resp.StatusCode)
+	}
+
+	var info struct {
+		Size        int64  `json:"size"`
+		ContentType string `json:"contentType"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return 0, "", err
+	}
+	return info.Size, info.ContentType, nil
+}
@@ -1,30 +1,253 @@
 package upload
 
 import (
-	"path/filepath"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"social-media-app/api/auth"
+	"social-media-app/api/models"
+	"social-media-app/config"
+	"time"
 
+	"github.com/disintegration/imaging"
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
-func Upload(c *fiber.Ctx) error {
-	file, err := c.FormFile("file")
+const (
+	maxUploadSize  = 10 << 20 // 10 MiB
+	thumbnailWidth = 256
+	signedURLTTL   = 15 * time.Minute
+
+	// SignedURLTTL is exported so callers outside this package (e.g.
+	// post.PostHandler, which caches a post's resolved image URL) can
+	// size their own cache TTL relative to how long the signature lasts.
+	SignedURLTTL = signedURLTTL
+)
+
+// allowedMimeTypes maps a sniffed Content-Type to the extension used for
+// the generated object key; anything else is rejected.
+var allowedMimeTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type UploadHandler struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	cfg         *config.Config
+	storage     Storage
+}
+
+func NewUploadHandler(db *gorm.DB, redisClient *redis.Client, cfg *config.Config, storage Storage) *UploadHandler {
+	return &UploadHandler{db, redisClient, cfg, storage}
+}
+
+// Upload validates a multipart file, stores it under a UUID-based key in
+// the configured Storage backend, generates a thumbnail for images, and
+// persists an Attachment row. It returns the Attachment plus a short-lived
+// signed GET URL rather than a public path.
+func (h *UploadHandler) Upload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	fileHeader, err := c.FormFile("file")
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Failed to upload file"})
 	}
+	if fileHeader.Size > maxUploadSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": "File too large"})
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to read file"})
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to read file"})
+	}
+
+	sniffLen := 512
+	if len(data) < sniffLen {
+		sniffLen = len(data)
+	}
+	contentType := http.DetectContentType(data[:sniffLen])
+	ext, ok := allowedMimeTypes[contentType]
+	if !ok {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"message": "Unsupported file type: " + contentType})
+	}
+
+	ctx := context.Background()
+	key := uuid.New().String() + ext
+	bucket, err := h.storage.Put(ctx, key, data, contentType)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to store file: " + err.Error()})
+	}
+
+	attachment := models.Attachment{
+		OwnerID: userID,
+		Bucket:  bucket,
+		Key:     key,
+		Mime:    contentType,
+		Size:    int64(len(data)),
+	}
+	if thumbKey, err := h.generateThumbnail(ctx, data, contentType, ext); err == nil {
+		attachment.ThumbKey = thumbKey
+	}
 
-	name := c.FormValue("name")
-	if name == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "File name required"})
+	if err := h.db.Create(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	dst := filepath.Join("public/images", name)
-	if err := c.SaveFile(file, dst); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to save file"})
+	url, err := h.storage.SignedURL(ctx, key, signedURLTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to sign URL: " + err.Error()})
 	}
 
-	return c.JSON(fiber.Map{"message": "File Uploaded Successfully"})
+	return c.JSON(fiber.Map{"attachment": attachment, "url": url})
 }
 
-func Setup(api fiber.Router) {
-	api.Post("/upload", Upload)
-}
\ No newline at end of file
+// generateThumbnail decodes an image and resizes it down to
+// thumbnailWidth, storing the result under a "thumb-" prefixed key.
+func (h *UploadHandler) generateThumbnail(ctx context.Context, data []byte, contentType, ext string) (string, error) {
+	img, err := imaging.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	thumb := imaging.Resize(img, thumbnailWidth, 0, imaging.Lanczos)
+
+	format, err := imaging.FormatFromExtension(ext)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, thumb, format); err != nil {
+		return "", err
+	}
+
+	thumbKey := "thumb-" + uuid.New().String() + ext
+	if _, err := h.storage.Put(ctx, thumbKey, buf.Bytes(), contentType); err != nil {
+		return "", err
+	}
+	return thumbKey, nil
+}
+
+// PresignRequest is the body of PresignUpload: the client declares the
+// MIME type it intends to upload so the server can pick an extension and
+// reject unsupported types before handing out a PUT URL.
+type PresignRequest struct {
+	ContentType string `json:"contentType" validate:"required"`
+}
+
+// PresignUpload hands a client a short-lived PUT URL for the configured
+// Storage backend so it can upload directly instead of proxying bytes
+// through Upload. The caller passes the returned key back to whatever
+// resource (e.g. PostHandler.CreatePost) should reference the object
+// once ConfirmUpload has verified it actually landed in storage.
+func (h *UploadHandler) PresignUpload(c *fiber.Ctx) error {
+	var req PresignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	ext, ok := allowedMimeTypes[req.ContentType]
+	if !ok {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"message": "Unsupported file type: " + req.ContentType})
+	}
+
+	key := uuid.New().String() + ext
+	url, err := h.storage.PresignPut(context.Background(), key, req.ContentType, signedURLTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"key": key, "uploadUrl": url})
+}
+
+// ConfirmUpload checks that key, presigned by PresignUpload, now exists
+// in storage and is within limits, then returns a fresh signed GET URL
+// for it. Callers that want to keep the object should reference key
+// (e.g. models.Post.Image) only after this succeeds.
+func (h *UploadHandler) ConfirmUpload(c *fiber.Ctx) error {
+	key := c.Params("*")
+
+	ctx := context.Background()
+	size, _, err := h.storage.Head(ctx, key)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Object not found, upload may not have completed"})
+	}
+	if size > maxUploadSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": "File too large"})
+	}
+
+	url, err := h.storage.SignedURL(ctx, key, signedURLTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"key": key, "size": size, "url": url})
+}
+
+// ReceivePut is the local storage backend's stand-in for a real
+// presigned PUT endpoint: it checks the signature PresignPut handed out
+// and writes the request body under public/images/key.
+func (h *UploadHandler) ReceivePut(c *fiber.Ctx) error {
+	key := c.Params("*")
+	if !VerifyLocalSignature(h.cfg.JWTSecret, key, c.Query("expires"), c.Query("sig")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid or expired signature"})
+	}
+	if len(c.Body()) > maxUploadSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"message": "File too large"})
+	}
+	if _, err := h.storage.Put(context.Background(), key, c.Body(), c.Get("Content-Type")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// SignedURL re-signs an Attachment's GET URL, for clients whose previous
+// link has expired.
+func (h *UploadHandler) SignedURL(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var attachment models.Attachment
+	if err := h.db.Where("id = ?", id).First(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Attachment not found"})
+	}
+
+	url, err := h.storage.SignedURL(context.Background(), attachment.Key, signedURLTTL)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"url": url})
+}
+
+// ServeLocal serves an object written by the local storage backend after
+// verifying its signed "expires"/"sig" query parameters.
+func (h *UploadHandler) ServeLocal(c *fiber.Ctx) error {
+	key := c.Params("*")
+	if !VerifyLocalSignature(h.cfg.JWTSecret, key, c.Query("expires"), c.Query("sig")) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Invalid or expired signature"})
+	}
+	return c.SendFile("public/images/" + key)
+}
+
+func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client, cfg *config.Config) {
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		panic("Failed to initialize storage backend: " + err.Error())
+	}
+	handler := NewUploadHandler(db, redisClient, cfg, storage)
+
+	api.Post("/upload", auth.JWTMiddleware(cfg, redisClient), handler.Upload)
+	api.Get("/upload/:id/url", auth.JWTMiddleware(cfg, redisClient), handler.SignedURL)
+	api.Post("/upload/presign", auth.JWTMiddleware(cfg, redisClient), handler.PresignUpload)
+	api.Post("/upload/confirm/*", auth.JWTMiddleware(cfg, redisClient), handler.ConfirmUpload)
+	api.Put("/uploads/put/*", handler.ReceivePut)
+	api.Get("/uploads/*", handler.ServeLocal)
+}
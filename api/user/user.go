@@ -3,14 +3,21 @@ package user
 import (
 	"context"
 	"encoding/json"
-	"social-media-app/api/auth"
-	"social-media-app/api/models"
-	"social-media-app/config"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
+	"log"
+	"social-media-app/api/activitypub"
+	"social-media-app/api/auth"
+	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"social-media-app/api/pagination"
+	"social-media-app/api/ws"
+	"social-media-app/config"
+	"social-media-app/services"
+	"strings"
 )
 
 type UpdateUserRequest struct {
@@ -19,8 +26,8 @@ type UpdateUserRequest struct {
 	Password           string `json:"password"`
 	Firstname          string `json:"firstname"`
 	Lastname           string `json:"lastname"`
-	ProfilePicture     string `json:"profilePicture"`
-	CoverPicture       string `json:"coverPicture"`
+	ProfilePicture     string `json:"profilePicture"` // Attachment.ID
+	CoverPicture       string `json:"coverPicture"`   // Attachment.ID
 	About              string `json:"about"`
 	LivesIn            string `json:"livesIn"`
 	WorksAt            string `json:"worksAt"`
@@ -29,20 +36,126 @@ type UpdateUserRequest struct {
 }
 
 type UserHandler struct {
-	db          *gorm.DB
-	redisClient *redis.Client
+	db            *gorm.DB
+	redisClient   *redis.Client
+	ap            *activitypub.Handler
+	relationships *services.RelationshipService
+	broker        ws.Broker
 }
 
-func NewUserHandler(db *gorm.DB, redisClient *redis.Client) *UserHandler {
-	return &UserHandler{db, redisClient}
+func NewUserHandler(db *gorm.DB, redisClient *redis.Client, ap *activitypub.Handler, relationships *services.RelationshipService, broker ws.Broker) *UserHandler {
+	return &UserHandler{db, redisClient, ap, relationships, broker}
 }
 
+// RelationshipActionRequest is the body for PUT /user/relationships/:id,
+// used to accept or decline a pending friend request.
+type RelationshipActionRequest struct {
+	Action string `json:"action" validate:"required"` // accept | decline
+}
+
+// isRemoteActor reports whether id is a federated actor URI rather than a
+// local user ID.
+func isRemoteActor(id string) bool {
+	return strings.Contains(id, "://")
+}
+
+// GetAllUsers lists the user directory with cursor pagination rather than
+// a full-table scan.
 func (h *UserHandler) GetAllUsers(c *fiber.Ctx) error {
+	limit := pagination.ParseLimit(c.Query("limit"))
+	cursor, err := pagination.Decode(c.Query("cursor"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
 	var users []models.User
-	if err := h.db.Find(&users).Error; err != nil {
+	q := pagination.Apply(h.db.Model(&models.User{}), cursor, limit+1)
+	if err := q.Find(&users).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	var nextCursor string
+	if len(users) > limit {
+		last := users[limit-1]
+		nextCursor = pagination.Encode(last.CreatedAt, last.ID)
+		users = users[:limit]
+	}
+
+	return c.JSON(fiber.Map{"data": users, "next_cursor": nextCursor})
+}
+
+// ListFollowers returns accountID's followers (the other side of an
+// Active relationship pointed at it) with cursor pagination.
+func (h *UserHandler) ListFollowers(c *fiber.Ctx) error {
+	return h.listConnections(c, "related_id")
+}
+
+// ListFollowing returns the users accountID follows, with cursor
+// pagination.
+func (h *UserHandler) ListFollowing(c *fiber.Ctx) error {
+	return h.listConnections(c, "account_id")
+}
+
+// listConnections pages through Active relationship rows anchored on
+// anchorColumn = :id and resolves the other side of each row to a User,
+// preserving the relationship rows' cursor order.
+func (h *UserHandler) listConnections(c *fiber.Ctx, anchorColumn string) error {
+	targetID := c.Params("id")
+	limit := pagination.ParseLimit(c.Query("limit"))
+	cursor, err := pagination.Decode(c.Query("cursor"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	otherColumn := "account_id"
+	if anchorColumn == "account_id" {
+		otherColumn = "related_id"
+	}
+
+	var rels []models.Relationship
+	q := pagination.Apply(
+		h.db.Model(&models.Relationship{}).Where(anchorColumn+" = ? AND status = ?", targetID, services.RelationshipActive),
+		cursor, limit+1,
+	)
+	if err := q.Find(&rels).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-	return c.JSON(users)
+
+	var nextCursor string
+	if len(rels) > limit {
+		last := rels[limit-1]
+		nextCursor = pagination.Encode(last.CreatedAt, last.ID)
+		rels = rels[:limit]
+	}
+
+	ids := make([]string, len(rels))
+	for i, r := range rels {
+		if otherColumn == "account_id" {
+			ids[i] = r.AccountID
+		} else {
+			ids[i] = r.RelatedID
+		}
+	}
+
+	var fetched []models.User
+	if len(ids) > 0 {
+		if err := h.db.Where("id IN ?", ids).Find(&fetched).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	byID := make(map[string]models.User, len(fetched))
+	for _, u := range fetched {
+		byID[u.ID] = u
+	}
+	users := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			users = append(users, u)
+		}
+	}
+
+	return c.JSON(fiber.Map{"data": users, "next_cursor": nextCursor})
 }
 
 func (h *UserHandler) GetUser(c *fiber.Ctx) error {
@@ -82,7 +195,8 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	}
 
 	// Update fields if provided
-	if req.Password != "" {
+	passwordChanged := req.Password != ""
+	if passwordChanged {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to hash password"})
@@ -96,9 +210,15 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		user.Lastname = req.Lastname
 	}
 	if req.ProfilePicture != "" {
+		if err := h.validateAttachment(req.ProfilePicture, userID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
 		user.ProfilePicture = req.ProfilePicture
 	}
 	if req.CoverPicture != "" {
+		if err := h.validateAttachment(req.CoverPicture, userID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+		}
 		user.CoverPicture = req.CoverPicture
 	}
 	if req.About != "" {
@@ -121,15 +241,21 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": user.Username,
-		"id":       user.ID,
-	})
+	// A password change invalidates every other session: revoke the
+	// refresh-token chain and the access token used for this request so
+	// neither can be replayed once the new password is in effect.
+	if passwordChanged {
+		auth.RevokeAllRefreshTokens(h.db, userID)
+		if jti, _ := c.Locals("jti").(string); jti != "" {
+			auth.RevokeJTI(h.redisClient, jti)
+		}
+	}
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to load config"})
 	}
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	tokenString, err := auth.IssueAccessToken(&user, cfg.JWTSecret)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": "Failed to generate token"})
 	}
@@ -139,6 +265,16 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"user": user, "token": tokenString})
 }
 
+// validateAttachment ensures attachmentID exists and belongs to userID
+// before it can be referenced as a profile/cover picture.
+func (h *UserHandler) validateAttachment(attachmentID, userID string) error {
+	var attachment models.Attachment
+	if err := h.db.Where("id = ? AND owner_id = ?", attachmentID, userID).First(&attachment).Error; err != nil {
+		return fmt.Errorf("attachment not found")
+	}
+	return nil
+}
+
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	userID := c.Params("id")
 	currentUserID := c.Locals("user_id").(string)
@@ -165,122 +301,283 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 }
 
 func (h *UserHandler) FollowUser(c *fiber.Ctx) error {
-    followID := c.Params("id")
-    currentUserID := c.Locals("user_id").(string)
-
-    if followID == currentUserID {
-        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot follow yourself"})
-    }
-
-    tx := h.db.Begin()
-    defer func() {
-        if r := recover(); r != nil {
-            tx.Rollback()
-        }
-    }()
-
-    // Check if already following
-    var count int64
-    err := tx.Model(&models.User{}).
-        Joins("JOIN users u ON u.id = ?", currentUserID).
-        Where("users.id = ? AND ? = ANY(u.following)", followID, followID).
-        Count(&count).Error
-    if err != nil {
-        tx.Rollback()
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-    }
-    if count > 0 {
-        tx.Rollback()
-        return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "You are already following this user"})
-    }
-
-    // Update following list for current user
-    err = tx.Exec(`
-        UPDATE users 
-        SET following = array_append(following, ?) 
-        WHERE id = ? AND NOT ? = ANY(following)`,
-        followID, currentUserID, followID).Error
-    if err != nil {
-        tx.Rollback()
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-    }
-
-    // Update followers list for target user
-    err = tx.Exec(`
-        UPDATE users 
-        SET followers = array_append(followers, ?) 
-        WHERE id = ? AND NOT ? = ANY(followers)`,
-        currentUserID, followID, currentUserID).Error
-    if err != nil {
-        tx.Rollback()
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-    }
-
-    if err := tx.Commit().Error; err != nil {
-        return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
-    }
-
-    // Clear cache
-    h.redisClient.Del(context.Background(), "user:"+followID)
-    h.redisClient.Del(context.Background(), "user:"+currentUserID)
-
-    return c.JSON(fiber.Map{"message": "User followed successfully"})
-}
-
-func (h *UserHandler) UnfollowUser(c *fiber.Ctx) error {
-	unfollowID := c.Params("id")
+	followID := c.Params("id")
 	currentUserID := c.Locals("user_id").(string)
 
-	var unfollowUser, currentUser models.User
-	if err := h.db.Where("id = ?", unfollowID).First(&unfollowUser).Error; err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User to unfollow not found"})
+	if followID == currentUserID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot follow yourself"})
+	}
+
+	if isRemoteActor(followID) {
+		return h.followRemoteActor(c, followID, currentUserID)
+	}
+
+	ctx := context.Background()
+	if err := h.relationships.Follow(ctx, currentUserID, followID); err != nil {
+		switch err {
+		case services.ErrAlreadyExists:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "You are already following this user"})
+		case services.ErrBlocked:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "You cannot follow this user"})
+		case services.ErrSelfRelationship:
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot follow yourself"})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+		}
+	}
+
+	// Clear cache
+	h.redisClient.Del(ctx, "user:"+followID)
+	h.redisClient.Del(ctx, "user:"+currentUserID)
+
+	if err := ws.PublishEvent(h.broker, followID, fiber.Map{
+		"type":    "follow",
+		"user_id": currentUserID,
+	}); err != nil {
+		log.Printf("user: failed to publish follow event to %s: %v", followID, err)
 	}
+
+	return c.JSON(fiber.Map{"message": "User followed successfully"})
+}
+
+// followRemoteActor sends a signed Follow activity to a federated actor's
+// inbox and records it optimistically; the relationship becomes confirmed
+// once the remote server replies with Accept on the local inbox.
+func (h *UserHandler) followRemoteActor(c *fiber.Ctx, actorURI string, currentUserID string) error {
+	var currentUser models.User
 	if err := h.db.Where("id = ?", currentUserID).First(&currentUser).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Current user not found"})
 	}
 
-	if unfollowID == currentUserID {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot unfollow yourself"})
+	for _, following := range currentUser.Following {
+		if following == actorURI {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "You are already following this actor"})
+		}
 	}
 
-	for i, follower := range unfollowUser.Followers {
-		if follower == currentUserID {
-			unfollowUser.Followers = append(unfollowUser.Followers[:i], unfollowUser.Followers[i+1:]...)
-			break
-		}
+	if err := h.ap.DeliverFollow(&currentUser, actorURI); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"message": "Failed to reach remote actor: " + err.Error()})
+	}
+
+	currentUser.Following = append(currentUser.Following, actorURI)
+	if err := h.db.Save(&currentUser).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	h.redisClient.Del(context.Background(), "user:"+currentUserID)
+	return c.JSON(fiber.Map{"message": "Follow request sent to remote actor"})
+}
+
+// unfollowRemoteActor sends Undo{Follow} to the federated actor's inbox and
+// removes the local optimistic following entry.
+func (h *UserHandler) unfollowRemoteActor(c *fiber.Ctx, actorURI string, currentUserID string) error {
+	var currentUser models.User
+	if err := h.db.Where("id = ?", currentUserID).First(&currentUser).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "Current user not found"})
+	}
+
+	if err := h.ap.DeliverUndoFollow(&currentUser, actorURI); err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"message": "Failed to reach remote actor: " + err.Error()})
 	}
+
 	for i, following := range currentUser.Following {
-		if following == unfollowID {
+		if following == actorURI {
 			currentUser.Following = append(currentUser.Following[:i], currentUser.Following[i+1:]...)
 			break
 		}
 	}
-
-	if err := h.db.Save(&unfollowUser).Error; err != nil {
+	if err := h.db.Save(&currentUser).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
-	if err := h.db.Save(&currentUser).Error; err != nil {
+
+	h.redisClient.Del(context.Background(), "user:"+currentUserID)
+	return c.JSON(fiber.Map{"message": "Unfollowed remote actor"})
+}
+
+func (h *UserHandler) UnfollowUser(c *fiber.Ctx) error {
+	unfollowID := c.Params("id")
+	currentUserID := c.Locals("user_id").(string)
+
+	if isRemoteActor(unfollowID) {
+		return h.unfollowRemoteActor(c, unfollowID, currentUserID)
+	}
+
+	if unfollowID == currentUserID {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Cannot unfollow yourself"})
+	}
+
+	if err := h.relationships.Unfollow(currentUserID, unfollowID); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
 	}
 
-	unfollowUserJSON, _ := json.Marshal(unfollowUser)
-	currentUserJSON, _ := json.Marshal(currentUser)
-	h.redisClient.Set(context.Background(), "user:"+unfollowID, unfollowUserJSON, 3600)
-	h.redisClient.Set(context.Background(), "user:"+currentUserID, currentUserJSON, 3600)
+	ctx := context.Background()
+	h.redisClient.Del(ctx, "user:"+unfollowID)
+	h.redisClient.Del(ctx, "user:"+currentUserID)
 	return c.JSON(fiber.Map{"message": "Unfollowed successfully"})
 }
 
+// ListRelationships returns the authenticated user's relationships,
+// optionally filtered by status (Pending, Waiting, Friend, Active,
+// Blocked, BlockedBy).
+func (h *UserHandler) ListRelationships(c *fiber.Ctx) error {
+	currentUserID := c.Locals("user_id").(string)
+	status := c.Query("status")
+
+	rels, err := h.relationships.List(currentUserID, status)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(rels)
+}
+
+// SendRelationshipRequest sends a friend request to :id.
+func (h *UserHandler) SendRelationshipRequest(c *fiber.Ctx) error {
+	currentUserID := c.Locals("user_id").(string)
+	targetID := c.Params("id")
+
+	rel, err := h.relationships.SendRequest(context.Background(), currentUserID, targetID)
+	if err != nil {
+		return relationshipErrorResponse(c, err)
+	}
+	return c.JSON(rel)
+}
+
+// RespondRelationshipRequest accepts or declines a pending friend request
+// from :id.
+func (h *UserHandler) RespondRelationshipRequest(c *fiber.Ctx) error {
+	currentUserID := c.Locals("user_id").(string)
+	targetID := c.Params("id")
+
+	var req RelationshipActionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	switch req.Action {
+	case "accept":
+		if err := h.relationships.Accept(context.Background(), currentUserID, targetID); err != nil {
+			return relationshipErrorResponse(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "Friend request accepted"})
+	case "decline":
+		if err := h.relationships.Decline(currentUserID, targetID); err != nil {
+			return relationshipErrorResponse(c, err)
+		}
+		return c.JSON(fiber.Map{"message": "Friend request declined"})
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Action must be accept or decline"})
+	}
+}
+
+// RemoveRelationship unfriends :id.
+func (h *UserHandler) RemoveRelationship(c *fiber.Ctx) error {
+	currentUserID := c.Locals("user_id").(string)
+	targetID := c.Params("id")
+
+	if err := h.relationships.Unfriend(currentUserID, targetID); err != nil {
+		return relationshipErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "Unfriended successfully"})
+}
+
+// BlockRelationship blocks :id, preventing their posts and chats from
+// surfacing to the current user and vice versa.
+func (h *UserHandler) BlockRelationship(c *fiber.Ctx) error {
+	currentUserID := c.Locals("user_id").(string)
+	targetID := c.Params("id")
+
+	if err := h.relationships.Block(currentUserID, targetID); err != nil {
+		return relationshipErrorResponse(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "User blocked"})
+}
+
+// NotificationPrefsRequest is the body for PUT /user/me/notification-prefs.
+type NotificationPrefsRequest struct {
+	DigestInterval string `json:"digestInterval"`
+	EmailEnabled   *bool  `json:"emailEnabled"`
+}
+
+// UpdateNotificationPrefs lets the current user choose how often
+// reaction/comment/follow notifications are batched into a digest email
+// (see api/notification/digest), independent of the realtime WebSocket
+// path, which always fires immediately.
+func (h *UserHandler) UpdateNotificationPrefs(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	var req NotificationPrefsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": "User not found"})
+	}
+
+	if req.DigestInterval != "" {
+		if !digest.ValidIntervals[digest.Interval(req.DigestInterval)] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid digestInterval"})
+		}
+		user.DigestInterval = req.DigestInterval
+	}
+	if req.EmailEnabled != nil {
+		user.EmailEnabled = *req.EmailEnabled
+	}
+
+	if err := h.db.Save(&user).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"digestInterval": user.DigestInterval,
+		"emailEnabled":   user.EmailEnabled,
+	})
+}
+
+func relationshipErrorResponse(c *fiber.Ctx, err error) error {
+	switch err {
+	case services.ErrSelfRelationship:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrAlreadyExists:
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrBlocked:
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": err.Error()})
+	case services.ErrRelationshipFound:
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"message": err.Error()})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+}
+
 func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
-	handler := NewUserHandler(db, redisClient)
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		panic("Failed to load config: " + err.Error())
 	}
+	ap := activitypub.NewHandler(db, redisClient, cfg)
+	relationships := services.NewRelationshipService(db, redisClient)
+	broker, err := ws.NewBroker(cfg, redisClient)
+	if err != nil {
+		panic("Failed to set up realtime broker: " + err.Error())
+	}
+	handler := NewUserHandler(db, redisClient, ap, relationships, broker)
 	user := api.Group("/user")
+
+	// Registered ahead of the "/:id" routes below so "/relationships" and
+	// "/me" aren't swallowed by the ":id" param.
+	user.Put("/me/notification-prefs", auth.JWTMiddleware(cfg, redisClient), handler.UpdateNotificationPrefs)
+	user.Get("/relationships", auth.JWTMiddleware(cfg, redisClient), handler.ListRelationships)
+	user.Post("/relationships/:id", auth.JWTMiddleware(cfg, redisClient), handler.SendRelationshipRequest)
+	user.Put("/relationships/:id", auth.JWTMiddleware(cfg, redisClient), handler.RespondRelationshipRequest)
+	user.Delete("/relationships/:id", auth.JWTMiddleware(cfg, redisClient), handler.RemoveRelationship)
+	user.Post("/relationships/:id/block", auth.JWTMiddleware(cfg, redisClient), handler.BlockRelationship)
+
 	user.Get("/", handler.GetAllUsers)
 	user.Get("/:id", handler.GetUser)
-	user.Put("/:id", auth.JWTMiddleware(cfg), handler.UpdateUser)
-	user.Delete("/:id", auth.JWTMiddleware(cfg), handler.DeleteUser)
-	user.Put("/:id/follow", auth.JWTMiddleware(cfg), handler.FollowUser)
-	user.Put("/:id/unfollow", auth.JWTMiddleware(cfg), handler.UnfollowUser)
-}
\ No newline at end of file
+	user.Put("/:id", auth.JWTMiddleware(cfg, redisClient), handler.UpdateUser)
+	user.Delete("/:id", auth.JWTMiddleware(cfg, redisClient), handler.DeleteUser)
+	user.Put("/:id/follow", auth.JWTMiddleware(cfg, redisClient), handler.FollowUser)
+	user.Put("/:id/unfollow", auth.JWTMiddleware(cfg, redisClient), handler.UnfollowUser)
+	user.Get("/:id/followers", handler.ListFollowers)
+	user.Get("/:id/following", handler.ListFollowing)
+}
@@ -0,0 +1,255 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// deliveryQueueKey is the Redis list Notify enqueues onto and Dispatcher
+// pops from, mirroring activitypub's deliveryQueueKey.
+const deliveryQueueKey = "webhook:deliveries"
+
+const (
+	workerCount = 4
+	maxAttempts = 5
+	baseBackoff = 500 * time.Millisecond
+)
+
+// deliveryClient never follows redirects (a subscriber could otherwise
+// pass URL validation with a public address and then 3xx the request
+// somewhere internal) and dials through dialValidated, which pins the
+// connection to the specific IP it checked instead of trusting a
+// second, later DNS resolution.
+var deliveryClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DialContext: dialValidated,
+	},
+}
+
+// dialValidated resolves addr's host itself and rejects it unless every
+// resolved IP is public, then dials that exact IP. Checking the URL at
+// subscription/attempt time and separately letting the transport
+// re-resolve the hostname to dial would leave a DNS-rebinding gap: an
+// attacker-controlled low-TTL record could return a public IP for the
+// check and a private one moments later for the real connection. Doing
+// the resolution and the dial against the same looked-up address closes
+// that gap.
+func dialValidated(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("could not resolve webhook host")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return nil, fmt.Errorf("webhook url resolves to a disallowed address")
+		}
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, net.JoinHostPort(ips[0].String(), port))
+}
+
+// job is the payload queued on Redis for a Dispatcher worker to process.
+type job struct {
+	SubscriptionID string          `json:"subscriptionId"`
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// Notify enqueues event for delivery to every active subscription userID
+// has registered for it. Handlers call this right after publishing the
+// same event to Redis pub/sub for WebSocket clients, so a webhook
+// subscriber gets parity with a connected socket.
+func Notify(ctx context.Context, db *gorm.DB, redisClient *redis.Client, userID, event string, data interface{}) error {
+	var subs []models.WebhookSubscription
+	if err := db.Where("user_id = ? AND active = ?", userID, true).Find(&subs).Error; err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	for _, sub := range subs {
+		if !containsEvent(sub.Events, event) {
+			continue
+		}
+		queued, err := json.Marshal(job{SubscriptionID: sub.ID, Event: event, Payload: payload})
+		if err != nil {
+			continue
+		}
+		if err := redisClient.RPush(ctx, deliveryQueueKey, queued).Err(); err != nil {
+			log.Printf("webhook: failed to enqueue %s for subscription %s: %v", event, sub.ID, err)
+		}
+	}
+	return nil
+}
+
+func containsEvent(events models.StringArray, event string) bool {
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatcher pops queued webhook deliveries off Redis and fans them out
+// across a worker pool that signs and POSTs each payload, retrying
+// failures with exponential backoff.
+type Dispatcher struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	jobs        chan job
+}
+
+func NewDispatcher(db *gorm.DB, redisClient *redis.Client) *Dispatcher {
+	return &Dispatcher{db: db, redisClient: redisClient, jobs: make(chan job, 256)}
+}
+
+// Start launches workerCount delivery workers, then blocks popping jobs
+// off the Redis queue to feed them until ctx is cancelled.
+func (d *Dispatcher) Start(ctx context.Context) {
+	for i := 0; i < workerCount; i++ {
+		go d.worker(ctx)
+	}
+
+	for {
+		result, err := d.redisClient.BLPop(ctx, 5*time.Second, deliveryQueueKey).Result()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if len(result) < 2 {
+			continue
+		}
+		var j job
+		if err := json.Unmarshal([]byte(result[1]), &j); err != nil {
+			log.Printf("webhook: dropping malformed delivery job: %v", err)
+			continue
+		}
+		select {
+		case d.jobs <- j:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-d.jobs:
+			d.deliver(j)
+		}
+	}
+}
+
+// deliver POSTs job's payload to its subscription's URL, retrying up to
+// maxAttempts times with exponential backoff, and logs every attempt to
+// WebhookDelivery.
+func (d *Dispatcher) deliver(j job) {
+	var sub models.WebhookSubscription
+	if err := d.db.Where("id = ? AND active = ?", j.SubscriptionID, true).First(&sub).Error; err != nil {
+		return
+	}
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		statusCode, err := d.attempt(sub, j)
+		delivered := err == nil
+		d.logAttempt(sub.ID, j.Event, attempt, statusCode, err, delivered)
+		if delivered {
+			return
+		}
+		if attempt < maxAttempts {
+			time.Sleep(baseBackoff * time.Duration(1<<uint(attempt-1)))
+		}
+	}
+	log.Printf("webhook: giving up on %s for subscription %s after %d attempts", j.Event, sub.ID, maxAttempts)
+}
+
+// attempt makes a single delivery try and reports the response status
+// (0 if the request never got a response) and any error.
+func (d *Dispatcher) attempt(sub models.WebhookSubscription, j job) (int, error) {
+	// Re-check scheme/format on every attempt, not just at subscription
+	// time. The IP itself is validated per-connection by dialValidated.
+	if err := validateWebhookURL(sub.URL); err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(j.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Event", j.Event)
+	req.Header.Set("X-Signature", sign(sub.Secret, j.Payload))
+
+	resp, err := deliveryClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("received status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// sign returns the hex-encoded HMAC-SHA256 of payload keyed by secret, so
+// a receiver can verify X-Signature without secret ever leaving this
+// process except in the header it produced.
+func sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (d *Dispatcher) logAttempt(subscriptionID, event string, attempt, statusCode int, err error, delivered bool) {
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+	record := models.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		Event:          event,
+		Attempt:        attempt,
+		StatusCode:     statusCode,
+		Error:          errMsg,
+		Delivered:      delivered,
+	}
+	if err := d.db.Create(&record).Error; err != nil {
+		log.Printf("webhook: failed to log delivery attempt: %v", err)
+	}
+}
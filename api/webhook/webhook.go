@@ -0,0 +1,152 @@
+// Package webhook lets a user or third-party integration register a URL
+// to receive friend and notification events without holding open a
+// WebSocket connection. Handlers that already publish an event to Redis
+// pub/sub for the WebSocket gateway also call Notify, which enqueues a
+// delivery job for every matching subscription; a Dispatcher (see
+// dispatcher.go) fans those jobs out across a worker pool that signs and
+// POSTs them, retrying failures with exponential backoff and logging
+// every attempt to WebhookDelivery.
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"social-media-app/api/auth"
+	"social-media-app/api/models"
+	"social-media-app/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Event names delivered to subscribed webhooks.
+const (
+	EventFriendRequestSent     = "friend.request.sent"
+	EventFriendRequestAccepted = "friend.request.accepted"
+	EventNotificationCreated   = "notification.created"
+)
+
+// SubscriptionRequest registers a webhook: the URL to POST events to, a
+// secret used to HMAC-sign each payload, and which events to receive.
+type SubscriptionRequest struct {
+	URL    string   `json:"url" validate:"required"`
+	Secret string   `json:"secret" validate:"required"`
+	Events []string `json:"events" validate:"required"`
+}
+
+type Handler struct {
+	db *gorm.DB
+}
+
+func NewHandler(db *gorm.DB) *Handler {
+	return &Handler{db}
+}
+
+// CreateSubscription registers a new webhook subscription for the current
+// user.
+func (h *Handler) CreateSubscription(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req SubscriptionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.URL == "" || req.Secret == "" || len(req.Events) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "url, secret, and events are required"})
+	}
+	if err := validateWebhookURL(req.URL); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": err.Error()})
+	}
+
+	sub := models.WebhookSubscription{
+		UserID: userID,
+		URL:    req.URL,
+		Secret: req.Secret,
+		Events: models.StringArray(req.Events),
+		Active: true,
+	}
+	if err := h.db.Create(&sub).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(sub)
+}
+
+// ListSubscriptions returns the current user's registered webhooks.
+func (h *Handler) ListSubscriptions(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var subs []models.WebhookSubscription
+	if err := h.db.Where("user_id = ?", userID).Find(&subs).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(subs)
+}
+
+// DeleteSubscription removes :id, scoped to the caller so one user can't
+// delete another's webhook.
+func (h *Handler) DeleteSubscription(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	id := c.Params("id")
+
+	if err := h.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.WebhookSubscription{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Webhook deleted"})
+}
+
+// validateWebhookURL rejects subscription URLs that could be used to
+// make this server issue requests against itself or internal
+// infrastructure (SSRF): only https is allowed, and every address the
+// host resolves to must be public and routable. dispatcher.go calls
+// this again immediately before each delivery attempt, since DNS can
+// resolve differently (or rebind) between subscription time and then.
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url")
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use https")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("invalid webhook url")
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return fmt.Errorf("could not resolve webhook host")
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip) {
+			return fmt.Errorf("webhook url resolves to a disallowed address")
+		}
+	}
+	return nil
+}
+
+// isPublicIP reports whether ip is a globally routable address, i.e.
+// not loopback, private, link-local, multicast, or unspecified.
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsUnspecified() &&
+		!ip.IsMulticast()
+}
+
+// Setup configures the webhook subscription routes.
+func Setup(api fiber.Router, db *gorm.DB, redisClient *redis.Client) {
+	handler := NewHandler(db)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		panic("Failed to load config: " + err.Error())
+	}
+	webhooks := api.Group("/webhooks")
+	webhooks.Post("/", auth.JWTMiddleware(cfg, redisClient), handler.CreateSubscription)
+	webhooks.Get("/", auth.JWTMiddleware(cfg, redisClient), handler.ListSubscriptions)
+	webhooks.Delete("/:id", auth.JWTMiddleware(cfg, redisClient), handler.DeleteSubscription)
+}
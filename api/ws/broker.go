@@ -0,0 +1,250 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"social-media-app/config"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Message is a single event delivered on a topic, independent of which
+// Broker backend produced it.
+type Message struct {
+	Topic   string
+	Payload []byte
+}
+
+// Broker fans real-time events out across every process in the cluster
+// and tracks which users currently have a live connection somewhere in
+// that cluster. A node registers its local sockets with Join/Leave and
+// relays inbound events by Subscribe-ing to a user's topic, so Publish
+// never needs to know which node (if any) is actually holding that
+// user's socket — mirroring how clustered chat servers like Mattermost
+// route events between nodes through a shared broker instead of direct
+// socket writes.
+type Broker interface {
+	// Publish delivers payload to every current Subscribe(topic) caller
+	// across the cluster, including ones on this node.
+	Publish(ctx context.Context, topic string, payload []byte) error
+	// Subscribe returns a channel of messages published to topic. The
+	// channel is closed once ctx is canceled.
+	Subscribe(ctx context.Context, topic string) <-chan Message
+	// Join records connID as an open connection for userID, to be
+	// refreshed before ttl elapses by the caller's ping loop so a
+	// crashed node's entries expire instead of wedging a user "online"
+	// forever.
+	Join(ctx context.Context, userID, connID string, ttl time.Duration) error
+	// Leave removes connID from userID's presence immediately, rather
+	// than waiting on its TTL, on clean disconnect.
+	Leave(ctx context.Context, userID, connID string) error
+	// Presence reports the connection IDs userID currently holds across
+	// the whole cluster. An empty slice means no node can reach them.
+	Presence(ctx context.Context, userID string) ([]string, error)
+	// ActiveUsers reports every user with at least one live connection
+	// anywhere in the cluster.
+	ActiveUsers(ctx context.Context) ([]string, error)
+}
+
+// NewBroker builds the Broker backend selected by cfg.BrokerBackend,
+// defaulting to Redis when unset so existing single-Redis deployments
+// don't need any new configuration. Presence is always tracked in Redis
+// sets regardless of backend, since NATS core pub/sub has no storage of
+// its own to query "who's online" against.
+func NewBroker(cfg *config.Config, redisClient *redis.Client) (Broker, error) {
+	switch cfg.BrokerBackend {
+	case "", "redis":
+		return newRedisBroker(redisClient), nil
+	case "nats":
+		return newNatsBroker(cfg, redisClient)
+	default:
+		return nil, fmt.Errorf("unknown broker backend: %s", cfg.BrokerBackend)
+	}
+}
+
+// presence is the Redis-backed presence tracker shared by every Broker
+// implementation. A user's connections live in a per-user set whose
+// members are lazily reconciled against per-connection TTL keys, and
+// presence:global tracks which users have at least one surviving
+// connection so broadcastActiveUsers can return a cluster-wide list
+// without scanning every user key.
+type presence struct {
+	redisClient *redis.Client
+}
+
+func (p *presence) connKey(userID, connID string) string {
+	return "presence:conn:" + userID + ":" + connID
+}
+
+func (p *presence) userKey(userID string) string {
+	return "presence:user:" + userID
+}
+
+const presenceGlobalKey = "presence:global"
+
+func (p *presence) Join(ctx context.Context, userID, connID string, ttl time.Duration) error {
+	if err := p.redisClient.Set(ctx, p.connKey(userID, connID), "1", ttl).Err(); err != nil {
+		return err
+	}
+	if err := p.redisClient.SAdd(ctx, p.userKey(userID), connID).Err(); err != nil {
+		return err
+	}
+	return p.redisClient.SAdd(ctx, presenceGlobalKey, userID).Err()
+}
+
+func (p *presence) Leave(ctx context.Context, userID, connID string) error {
+	p.redisClient.Del(ctx, p.connKey(userID, connID))
+	if err := p.redisClient.SRem(ctx, p.userKey(userID), connID).Err(); err != nil {
+		return err
+	}
+	return p.reapIfEmpty(ctx, userID)
+}
+
+// Presence returns the connection IDs still within their TTL, lazily
+// evicting any that expired without a clean Leave call.
+func (p *presence) Presence(ctx context.Context, userID string) ([]string, error) {
+	connIDs, err := p.redisClient.SMembers(ctx, p.userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	alive := make([]string, 0, len(connIDs))
+	for _, connID := range connIDs {
+		exists, err := p.redisClient.Exists(ctx, p.connKey(userID, connID)).Result()
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			p.redisClient.SRem(ctx, p.userKey(userID), connID)
+			continue
+		}
+		alive = append(alive, connID)
+	}
+
+	if len(alive) == 0 {
+		p.reapIfEmpty(ctx, userID)
+	}
+	return alive, nil
+}
+
+func (p *presence) reapIfEmpty(ctx context.Context, userID string) error {
+	count, err := p.redisClient.SCard(ctx, p.userKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	p.redisClient.Del(ctx, p.userKey(userID))
+	return p.redisClient.SRem(ctx, presenceGlobalKey, userID).Err()
+}
+
+func (p *presence) ActiveUsers(ctx context.Context) ([]string, error) {
+	userIDs, err := p.redisClient.SMembers(ctx, presenceGlobalKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	active := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		conns, err := p.Presence(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if len(conns) > 0 {
+			active = append(active, userID)
+		}
+	}
+	return active, nil
+}
+
+// redisBroker publishes over Redis PUBLISH/SUBSCRIBE, giving every node
+// that shares the same Redis instance a consistent event and presence
+// view. It's the default backend since the rest of the API already
+// depends on Redis for caching, sessions, and the notification digest.
+type redisBroker struct {
+	presence
+	redisClient *redis.Client
+}
+
+func newRedisBroker(redisClient *redis.Client) *redisBroker {
+	return &redisBroker{presence: presence{redisClient: redisClient}, redisClient: redisClient}
+}
+
+func (b *redisBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	return b.redisClient.Publish(ctx, topic, payload).Err()
+}
+
+func (b *redisBroker) Subscribe(ctx context.Context, topic string) <-chan Message {
+	pubsub := b.redisClient.Subscribe(ctx, topic)
+	out := make(chan Message)
+
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-pubsub.Channel():
+				if !ok {
+					return
+				}
+				select {
+				case out <- Message{Topic: msg.Channel, Payload: []byte(msg.Payload)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// natsBroker publishes over NATS core pub/sub, for deployments that
+// already run a NATS cluster for other services and would rather not
+// route every real-time event through Redis. Presence still goes
+// through the same Redis-backed tracker as redisBroker, since that's
+// the piece NATS core has no equivalent for.
+type natsBroker struct {
+	presence
+	conn *nats.Conn
+}
+
+func newNatsBroker(cfg *config.Config, redisClient *redis.Client) (*natsBroker, error) {
+	conn, err := nats.Connect(cfg.NatsURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+	return &natsBroker{presence: presence{redisClient: redisClient}, conn: conn}, nil
+}
+
+func (b *natsBroker) Publish(ctx context.Context, topic string, payload []byte) error {
+	return b.conn.Publish(topic, payload)
+}
+
+func (b *natsBroker) Subscribe(ctx context.Context, topic string) <-chan Message {
+	out := make(chan Message)
+
+	sub, err := b.conn.Subscribe(topic, func(msg *nats.Msg) {
+		select {
+		case out <- Message{Topic: msg.Subject, Payload: msg.Data}:
+		case <-ctx.Done():
+		}
+	})
+	if err != nil {
+		close(out)
+		return out
+	}
+
+	go func() {
+		<-ctx.Done()
+		sub.Unsubscribe()
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,378 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"social-media-app/api/models"
+	"social-media-app/api/notification/digest"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// ringingTimeout is how long an invitee has to call-accept/call-reject
+// before CallManager marks them missed automatically.
+const ringingTimeout = 30 * time.Second
+
+// CallManager drives the Call/CallParticipant state machine
+// (ringing -> active -> ended/missed) behind the call-invite/
+// call-accept/call-reject/call-leave socket messages, and
+// broadcasts each transition to every other participant via broker so a
+// group call's members can be spread across nodes.
+type CallManager struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	broker      Broker
+
+	mu     sync.Mutex
+	timers map[string]map[string]*time.Timer // callID -> userID -> ringing timer
+}
+
+func NewCallManager(db *gorm.DB, redisClient *redis.Client, broker Broker) *CallManager {
+	return &CallManager{
+		db:          db,
+		redisClient: redisClient,
+		broker:      broker,
+		timers:      make(map[string]map[string]*time.Timer),
+	}
+}
+
+func (m *CallManager) notifyUser(userID string, event fiber.Map) {
+	if err := PublishEvent(m.broker, userID, event); err != nil {
+		log.Printf("ws: failed to publish %v to %s: %v", event["type"], userID, err)
+	}
+}
+
+func (m *CallManager) notifyOthers(participants []models.CallParticipant, exceptUserID string, event fiber.Map) {
+	for _, p := range participants {
+		if p.UserID == exceptUserID {
+			continue
+		}
+		m.notifyUser(p.UserID, event)
+	}
+}
+
+// Invite creates a ringing Call for channel with initiatorID already
+// joined, starts a per-invitee ringing timer, and notifies every invitee.
+func (m *CallManager) Invite(initiatorID, channel, callType string, targetIDs []string) (*models.Call, error) {
+	now := time.Now()
+	call := &models.Call{
+		Channel:     channel,
+		InitiatorID: initiatorID,
+		Type:        callType,
+		Status:      "ringing",
+		StartedAt:   now,
+	}
+	if err := m.db.Create(call).Error; err != nil {
+		return nil, err
+	}
+
+	if err := m.db.Create(&models.CallParticipant{
+		CallID: call.ID, UserID: initiatorID, State: "joined", JoinedAt: &now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	for _, targetID := range targetIDs {
+		if err := m.db.Create(&models.CallParticipant{
+			CallID: call.ID, UserID: targetID, State: "ringing",
+		}).Error; err != nil {
+			log.Printf("ws: failed to add call participant %s: %v", targetID, err)
+			continue
+		}
+		m.notifyUser(targetID, fiber.Map{
+			"type":      "call-invite",
+			"call_id":   call.ID,
+			"channel":   channel,
+			"call_type": callType,
+			"from":      initiatorID,
+		})
+		m.startRingingTimer(call.ID, targetID)
+	}
+
+	return call, nil
+}
+
+func (m *CallManager) startRingingTimer(callID, userID string) {
+	m.mu.Lock()
+	if m.timers[callID] == nil {
+		m.timers[callID] = make(map[string]*time.Timer)
+	}
+	m.timers[callID][userID] = time.AfterFunc(ringingTimeout, func() {
+		m.markMissed(callID, userID)
+	})
+	m.mu.Unlock()
+}
+
+func (m *CallManager) stopRingingTimer(callID, userID string) {
+	m.mu.Lock()
+	if timers, ok := m.timers[callID]; ok {
+		if t, ok := timers[userID]; ok {
+			t.Stop()
+			delete(timers, userID)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// markMissed transitions userID to "missed" if they're still ringing,
+// notifies the initiator, sends a missed-call notification through the
+// existing notification pipeline, and ends the call if nobody else ever
+// answered.
+func (m *CallManager) markMissed(callID, userID string) {
+	m.stopRingingTimer(callID, userID)
+
+	var participant models.CallParticipant
+	if err := m.db.Where("call_id = ? AND user_id = ?", callID, userID).First(&participant).Error; err != nil {
+		return
+	}
+	if participant.State != "ringing" {
+		return
+	}
+	participant.State = "missed"
+	if err := m.db.Save(&participant).Error; err != nil {
+		log.Printf("ws: failed to mark call %s participant %s missed: %v", callID, userID, err)
+		return
+	}
+
+	var call models.Call
+	if err := m.db.Where("id = ?", callID).First(&call).Error; err != nil {
+		return
+	}
+
+	m.sendMissedCallNotification(call, userID)
+	m.notifyUser(call.InitiatorID, fiber.Map{
+		"type":    "call-missed",
+		"call_id": callID,
+		"user_id": userID,
+	})
+
+	m.endIfNobodyLeft(call)
+}
+
+func (m *CallManager) sendMissedCallNotification(call models.Call, userID string) {
+	var initiator models.User
+	if err := m.db.Where("id = ?", call.InitiatorID).First(&initiator).Error; err != nil {
+		return
+	}
+
+	notification := models.Notification{
+		UserID:     userID,
+		Type:       "missed_call",
+		FromUserID: call.InitiatorID,
+		Message:    initiator.Username + " called you",
+	}
+	if err := m.db.Create(&notification).Error; err != nil {
+		log.Printf("ws: failed to persist missed-call notification for %s: %v", userID, err)
+		return
+	}
+
+	if err := PublishEvent(m.broker, userID, fiber.Map{
+		"type": "notification",
+		"data": notification,
+	}); err != nil {
+		log.Printf("ws: failed to publish missed-call notification to %s: %v", userID, err)
+	}
+
+	if err := digest.Enqueue(context.Background(), m.db, m.redisClient, userID, digest.Event{
+		Type: "missed_call", FromUserID: call.InitiatorID, FromUsername: initiator.Username,
+		CreatedAt: notification.CreatedAt,
+	}); err != nil {
+		log.Printf("ws: failed to enqueue missed-call digest event for %s: %v", userID, err)
+	}
+}
+
+// Accept transitions userID to joined, marks the call active, and tells
+// every other participant.
+func (m *CallManager) Accept(callID, userID string) error {
+	m.stopRingingTimer(callID, userID)
+
+	now := time.Now()
+	if err := m.db.Model(&models.CallParticipant{}).
+		Where("call_id = ? AND user_id = ?", callID, userID).
+		Updates(map[string]interface{}{"state": "joined", "joined_at": now}).Error; err != nil {
+		return err
+	}
+
+	m.db.Model(&models.Call{}).Where("id = ? AND status = ?", callID, "ringing").
+		Update("status", "active")
+
+	participants, err := m.participants(callID)
+	if err != nil {
+		return err
+	}
+	m.notifyOthers(participants, userID, fiber.Map{
+		"type":    "call-accepted",
+		"call_id": callID,
+		"user_id": userID,
+	})
+	return nil
+}
+
+// Reject transitions userID to rejected and ends the call if nobody is
+// left to answer.
+func (m *CallManager) Reject(callID, userID string) error {
+	m.stopRingingTimer(callID, userID)
+
+	if err := m.db.Model(&models.CallParticipant{}).
+		Where("call_id = ? AND user_id = ?", callID, userID).
+		Update("state", "rejected").Error; err != nil {
+		return err
+	}
+
+	var call models.Call
+	if err := m.db.Where("id = ?", callID).First(&call).Error; err != nil {
+		return err
+	}
+	participants, err := m.participants(callID)
+	if err != nil {
+		return err
+	}
+	m.notifyOthers(participants, userID, fiber.Map{
+		"type":    "call-rejected",
+		"call_id": callID,
+		"user_id": userID,
+	})
+
+	m.endIfNobodyLeft(call)
+	return nil
+}
+
+// Leave transitions userID to left and ends the call once no joined
+// participant remains.
+func (m *CallManager) Leave(callID, userID string) error {
+	m.stopRingingTimer(callID, userID)
+
+	now := time.Now()
+	if err := m.db.Model(&models.CallParticipant{}).
+		Where("call_id = ? AND user_id = ?", callID, userID).
+		Updates(map[string]interface{}{"state": "left", "left_at": now}).Error; err != nil {
+		return err
+	}
+
+	var call models.Call
+	if err := m.db.Where("id = ?", callID).First(&call).Error; err != nil {
+		return err
+	}
+	participants, err := m.participants(callID)
+	if err != nil {
+		return err
+	}
+	m.notifyOthers(participants, userID, fiber.Map{
+		"type":    "call-left",
+		"call_id": callID,
+		"user_id": userID,
+	})
+
+	m.endIfNobodyLeft(call)
+	return nil
+}
+
+func (m *CallManager) participants(callID string) ([]models.CallParticipant, error) {
+	var participants []models.CallParticipant
+	err := m.db.Where("call_id = ?", callID).Find(&participants).Error
+	return participants, err
+}
+
+// endIfNobodyLeft ends call once it has no more "joined" participants,
+// whether that's because the last one left or because every invitee
+// rejected/missed before anyone answered.
+func (m *CallManager) endIfNobodyLeft(call models.Call) {
+	if call.Status == "ended" || call.Status == "missed" {
+		return
+	}
+
+	var joined int64
+	m.db.Model(&models.CallParticipant{}).Where("call_id = ? AND state = ?", call.ID, "joined").Count(&joined)
+	if call.Status == "ringing" {
+		// The initiator is joined from Invite on, so a call nobody has
+		// answered also ends once no invitee is still ringing.
+		var ringing int64
+		m.db.Model(&models.CallParticipant{}).Where("call_id = ? AND state = ?", call.ID, "ringing").Count(&ringing)
+		if joined > 0 && ringing > 0 {
+			return
+		}
+	} else if joined > 0 {
+		return
+	}
+
+	now := time.Now()
+	status := "ended"
+	if call.Status == "ringing" {
+		status = "missed"
+	}
+	m.db.Model(&models.Call{}).Where("id = ?", call.ID).
+		Updates(map[string]interface{}{"status": status, "ended_at": now})
+
+	participants, err := m.participants(call.ID)
+	if err != nil {
+		return
+	}
+	for _, p := range participants {
+		m.notifyUser(p.UserID, fiber.Map{
+			"type":    "call-ended",
+			"call_id": call.ID,
+		})
+	}
+}
+
+// callHistoryEntry is what GET /call/history returns per call.
+type callHistoryEntry struct {
+	CallID       string                   `json:"call_id"`
+	Channel      string                   `json:"channel"`
+	Type         string                   `json:"type"`
+	Status       string                   `json:"status"`
+	StartedAt    time.Time                `json:"started_at"`
+	EndedAt      *time.Time               `json:"ended_at,omitempty"`
+	DurationSecs int64                    `json:"duration_seconds"`
+	Participants []models.CallParticipant `json:"participants"`
+}
+
+// History returns userID's calls, most recent first, with duration and
+// participant state for each.
+func (m *CallManager) History(userID string) ([]callHistoryEntry, error) {
+	var callIDs []string
+	if err := m.db.Model(&models.CallParticipant{}).
+		Where("user_id = ?", userID).Pluck("call_id", &callIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(callIDs) == 0 {
+		return []callHistoryEntry{}, nil
+	}
+
+	var calls []models.Call
+	if err := m.db.Where("id IN ?", callIDs).Order("started_at desc").Find(&calls).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make([]callHistoryEntry, 0, len(calls))
+	for _, call := range calls {
+		participants, err := m.participants(call.ID)
+		if err != nil {
+			return nil, err
+		}
+		var duration int64
+		if call.EndedAt != nil {
+			duration = int64(call.EndedAt.Sub(call.StartedAt).Seconds())
+		}
+		entries = append(entries, callHistoryEntry{
+			CallID: call.ID, Channel: call.Channel, Type: call.Type, Status: call.Status,
+			StartedAt: call.StartedAt, EndedAt: call.EndedAt, DurationSecs: duration,
+			Participants: participants,
+		})
+	}
+	return entries, nil
+}
+
+// CallHistoryHTTP backs GET /call/history.
+func CallHistoryHTTP(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	entries, err := calls.History(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"data": entries})
+}
@@ -0,0 +1,110 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"social-media-app/api/auth"
+	"social-media-app/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var gatewayHub *Hub
+
+// Shutdown closes every connection registered with either the
+// call-signaling hub or the gateway hub. It is called on SIGTERM so
+// clients are told to reconnect instead of the socket just dropping.
+func Shutdown() {
+	if hub != nil {
+		hub.CloseAll()
+	}
+	if gatewayHub != nil {
+		gatewayHub.CloseAll()
+	}
+}
+
+// PublishEvent publishes event on the topic every gateway connection for
+// userID subscribes to, through the cluster-wide Broker backing the
+// gateway hub. Handlers push a real-time event (a new chat message, a
+// new follower, ...) through here rather than touching a socket
+// directly, since the recipient may be connected to a different
+// instance.
+func PublishEvent(broker Broker, userID string, event fiber.Map) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return broker.Publish(context.Background(), "user:"+userID, payload)
+}
+
+// SetupGateway mounts the authenticated real-time event gateway at
+// GET /ws/gateway, separate from the legacy call-signaling socket above.
+// A connection is tied to a JWT-verified user for its whole lifetime and
+// receives whatever is published for user:<id>, routed through broker so
+// it reaches this node whether the event originated here or on a peer.
+func SetupGateway(app fiber.Router, broker Broker, cfg *config.Config, redisClient *redis.Client) {
+	gatewayHub = NewHub(broker)
+
+	app.Use("/gateway", func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+
+		// Browsers can't set an Authorization header on the WebSocket
+		// handshake, so the token also travels as a query param or, for
+		// clients that prefer not to put it in the URL, the
+		// Sec-WebSocket-Protocol header.
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			tokenString = c.Get("Sec-WebSocket-Protocol")
+		}
+
+		userID, _, _, err := auth.ParseUserFromToken(tokenString, cfg, redisClient)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"message": err.Error()})
+		}
+
+		c.Locals("gateway_user_id", userID)
+		return c.Next()
+	})
+
+	app.Get("/gateway", websocket.New(handleGatewayConn))
+}
+
+func handleGatewayConn(c *websocket.Conn) {
+	userID, _ := c.Locals("gateway_user_id").(string)
+	if userID == "" {
+		c.Close()
+		return
+	}
+	connID := uuid.NewString()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	send, unregister := gatewayHub.Register(ctx, userID, connID, c)
+	defer unregister()
+
+	c.SetReadLimit(wsReadLimit)
+	c.SetReadDeadline(time.Now().Add(60 * time.Second))
+	c.SetPongHandler(func(string) error {
+		c.SetReadDeadline(time.Now().Add(60 * time.Second))
+		return nil
+	})
+
+	go runWritePump(gatewayHub, c, userID, connID, send)
+
+	for {
+		if _, _, err := c.ReadMessage(); err != nil {
+			break
+		}
+	}
+
+	log.Printf("ws gateway: user %s disconnected", userID)
+}
@@ -0,0 +1,225 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// globalTopic is the broker topic every connection subscribes to
+// alongside its own "user:{id}" topic, used for events meant for every
+// connected user regardless of which one of them caused them, such as
+// broadcastActiveUsers' cluster-wide online list.
+const globalTopic = "presence:global"
+
+// presenceTTL bounds how long a connection's presence entry survives
+// without being refreshed by its ping loop. A node that crashes without
+// a clean disconnect still ages out of presence within this window
+// instead of wedging a user "online" forever.
+const presenceTTL = 90 * time.Second
+
+// hubSendBuffer bounds the number of queued outbound messages per
+// connection. A client that can't keep up within this many messages is
+// evicted entirely (see relay) rather than let delivery to everyone
+// else back up behind it.
+const hubSendBuffer = 64
+
+// hubConn is one locally-registered connection: the socket itself, the
+// channel its write pump drains, and the cancel func that tears down its
+// broker subscriptions and presence entry on disconnect. once guards
+// against the connection being torn down twice, since both a slow-client
+// eviction and the client's own disconnect can race to unregister it.
+type hubConn struct {
+	conn   *websocket.Conn
+	send   chan []byte
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+// Hub tracks the connections this node is holding locally and relays
+// events for them through a Broker, so publishing for a user reaches
+// whichever node (if any) is actually holding their socket, and a node
+// never writes to a socket except from its own subscription delivering
+// a message — it never reaches into another goroutine's connection.
+type Hub struct {
+	broker Broker
+
+	mu    sync.Mutex
+	conns map[string]map[string]*hubConn // userID -> connID -> conn
+}
+
+// NewHub wraps broker in a Hub for one node to register its local
+// sockets against.
+func NewHub(broker Broker) *Hub {
+	return &Hub{broker: broker, conns: make(map[string]map[string]*hubConn)}
+}
+
+// Register joins userID's presence under connID and subscribes to its
+// topic plus globalTopic, returning the channel the caller's write pump
+// should drain and a function to call exactly once on disconnect. conn
+// is kept only so CloseAll can close it on shutdown; nothing else ever
+// writes to it directly.
+func (h *Hub) Register(ctx context.Context, userID, connID string, conn *websocket.Conn) (send <-chan []byte, unregister func()) {
+	connCtx, cancel := context.WithCancel(ctx)
+	hc := &hubConn{conn: conn, send: make(chan []byte, hubSendBuffer), cancel: cancel}
+
+	h.mu.Lock()
+	if h.conns[userID] == nil {
+		h.conns[userID] = make(map[string]*hubConn)
+	}
+	h.conns[userID][connID] = hc
+	h.mu.Unlock()
+
+	if err := h.broker.Join(connCtx, userID, connID, presenceTTL); err != nil {
+		log.Printf("ws hub: join presence for %s: %v", userID, err)
+	}
+
+	go h.relay(connCtx, userID, connID, hc, "user:"+userID)
+	go h.relay(connCtx, userID, connID, hc, globalTopic)
+
+	unregister = func() {
+		h.evict(userID, connID, hc)
+	}
+
+	return hc.send, unregister
+}
+
+// evict tears down userID/connID's registration: it drops hc from conns,
+// cancels its subscriptions, clears its presence entry, and closes both
+// the send channel and the underlying socket so a blocked read pump
+// unblocks with an error and its handler's own cleanup runs. once makes
+// this safe to call both from the client's own disconnect and from
+// relay's slow-client eviction without double-closing anything.
+func (h *Hub) evict(userID, connID string, hc *hubConn) {
+	hc.once.Do(func() {
+		h.mu.Lock()
+		delete(h.conns[userID], connID)
+		if len(h.conns[userID]) == 0 {
+			delete(h.conns, userID)
+		}
+		h.mu.Unlock()
+
+		hc.cancel()
+		if err := h.broker.Leave(context.Background(), userID, connID); err != nil {
+			log.Printf("ws hub: leave presence for %s: %v", userID, err)
+		}
+		close(hc.send)
+		hc.conn.Close()
+	})
+}
+
+// relay forwards every message the broker delivers on topic into hc's
+// send channel until ctx is canceled. A client slow enough to fill its
+// send buffer is evicted outright rather than having individual events
+// silently dropped forever, since a backlog that long means the
+// connection is never going to catch up.
+func (h *Hub) relay(ctx context.Context, userID, connID string, hc *hubConn, topic string) {
+	for msg := range h.broker.Subscribe(ctx, topic) {
+		select {
+		case hc.send <- msg.Payload:
+		default:
+			log.Printf("ws hub: evicting slow client %s on %s, send buffer full", userID, topic)
+			go h.evict(userID, connID, hc)
+			return
+		}
+	}
+}
+
+// JoinPresence records a presence-only connection for userID under
+// connID, without subscribing it to any topic. Callers that deliver
+// their own events directly instead of draining Register's send channel
+// (like message.HandleWebSocket's chat/notification pubsub loop) use
+// this so they're tracked in the same cluster-wide presence set without
+// an unread relay channel piling up behind them.
+func (h *Hub) JoinPresence(ctx context.Context, userID, connID string) {
+	if err := h.broker.Join(ctx, userID, connID, presenceTTL); err != nil {
+		log.Printf("ws hub: join presence for %s: %v", userID, err)
+	}
+}
+
+// LeavePresence undoes JoinPresence.
+func (h *Hub) LeavePresence(ctx context.Context, userID, connID string) {
+	if err := h.broker.Leave(ctx, userID, connID); err != nil {
+		log.Printf("ws hub: leave presence for %s: %v", userID, err)
+	}
+}
+
+// Refresh extends connID's presence TTL; call it from the connection's
+// ping loop so a socket that's still alive doesn't age out of presence
+// between writes.
+func (h *Hub) Refresh(ctx context.Context, userID, connID string) {
+	if err := h.broker.Join(ctx, userID, connID, presenceTTL); err != nil {
+		log.Printf("ws hub: refresh presence for %s: %v", userID, err)
+	}
+}
+
+// Publish fans payload out to every node holding a connection for
+// userID, including this one.
+func (h *Hub) Publish(ctx context.Context, userID string, payload []byte) error {
+	return h.broker.Publish(ctx, "user:"+userID, payload)
+}
+
+// PublishGlobal fans payload out to every connected user on every node,
+// e.g. the active-users list.
+func (h *Hub) PublishGlobal(ctx context.Context, payload []byte) error {
+	return h.broker.Publish(ctx, globalTopic, payload)
+}
+
+// ActiveUsers reports every user with a live connection anywhere in the
+// cluster.
+func (h *Hub) ActiveUsers(ctx context.Context) ([]string, error) {
+	return h.broker.ActiveUsers(ctx)
+}
+
+// IsOnline reports whether userID has a live connection anywhere in the
+// cluster, i.e. whether Publish can actually reach them right now.
+func (h *Hub) IsOnline(ctx context.Context, userID string) bool {
+	conns, err := h.broker.Presence(ctx, userID)
+	if err != nil {
+		log.Printf("ws hub: presence lookup for %s: %v", userID, err)
+		return false
+	}
+	return len(conns) > 0
+}
+
+// CloseAll closes every locally-registered connection so clients see a
+// clean close frame instead of the TCP connection dying silently, used
+// on shutdown.
+func (h *Hub) CloseAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, byConn := range h.conns {
+		for _, hc := range byConn {
+			hc.conn.Close()
+		}
+	}
+}
+
+// runWritePump drains send onto conn as text frames, interleaving
+// periodic pings so a single goroutine ever calls WriteMessage for
+// writes, and refreshes the connection's presence TTL on the same
+// ticker so a socket that's still pumping writes doesn't age out.
+func runWritePump(hub *Hub, conn *websocket.Conn, userID, connID string, send <-chan []byte) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case payload, ok := <-send:
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			hub.Refresh(context.Background(), userID, connID)
+		}
+	}
+}
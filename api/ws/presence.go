@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// JoinPresence registers connID as an open connection for userID and,
+// if this is their first session anywhere in the cluster, broadcasts an
+// "online" presence event on globalTopic. It's exported so other
+// real-time endpoints (e.g. message.HandleWebSocket) that don't use
+// Hub.Register for event delivery can still be tracked in the same
+// cluster-wide presence set hub and gatewayHub already share, since
+// both wrap the same Redis-backed presence store.
+func JoinPresence(ctx context.Context, userID, connID string) {
+	wasOnline := hub.IsOnline(ctx, userID)
+	hub.JoinPresence(ctx, userID, connID)
+	if !wasOnline {
+		broadcastPresenceEvent(ctx, userID, "online")
+	}
+}
+
+// LeavePresence undoes JoinPresence, broadcasting an "offline" event
+// only once userID's session set is empty, so one of several connected
+// devices disconnecting doesn't flip them offline for the others.
+func LeavePresence(ctx context.Context, userID, connID string) {
+	hub.LeavePresence(ctx, userID, connID)
+	if !hub.IsOnline(ctx, userID) {
+		broadcastPresenceEvent(ctx, userID, "offline")
+	}
+}
+
+// RefreshPresence extends connID's presence TTL; call it on a heartbeat
+// so a connection that's still alive but otherwise idle doesn't age out
+// of presence between events.
+func RefreshPresence(ctx context.Context, userID, connID string) {
+	hub.Refresh(ctx, userID, connID)
+}
+
+func broadcastPresenceEvent(ctx context.Context, userID, event string) {
+	payload, err := json.Marshal(map[string]interface{}{"type": "presence", "event": event, "userId": userID})
+	if err != nil {
+		return
+	}
+	if err := hub.PublishGlobal(ctx, payload); err != nil {
+		log.Printf("ws: failed to broadcast presence event for %s: %v", userID, err)
+	}
+}
+
+// GetUserPresence reports whether :userId has a live connection
+// anywhere in the cluster right now.
+func GetUserPresence(c *fiber.Ctx) error {
+	userID := c.Params("userId")
+	online := hub.IsOnline(context.Background(), userID)
+	return c.JSON(fiber.Map{"userId": userID, "online": online})
+}
+
+// GetActivePresence lists every user with a live connection anywhere in
+// the cluster.
+func GetActivePresence(c *fiber.Ctx) error {
+	userIDs, err := hub.ActiveUsers(context.Background())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(fiber.Map{"userIds": userIDs})
+}
@@ -0,0 +1,311 @@
+package ws
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"social-media-app/api/models"
+	"social-media-app/config"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// agoraRecordingBaseURL is Agora's Cloud Recording REST API root.
+// https://docs.agora.io/en/cloud-recording/reference/restful-api
+const agoraRecordingBaseURL = "https://api.agora.io/v1/apps"
+
+// s3RegionCodes maps a handful of AWS regions to Agora's numeric storage
+// region codes, which don't match AWS's own region strings. It is keyed
+// by cfg.S3Region, the same setting api/upload/s3.go uses; a region not
+// listed here maps to 0, Agora's code for us-east-1.
+var s3RegionCodes = map[string]int{
+	"us-east-1":    0,
+	"us-east-2":    1,
+	"us-west-1":    2,
+	"us-west-2":    3,
+	"eu-west-1":    4,
+	"eu-central-1": 6,
+}
+
+// RecordingService drives Agora's Cloud Recording REST API (acquire,
+// start, stop, query) on behalf of the initiator recorded in
+// activeCalls, and persists the resulting session as a CallRecording row
+// so StopRecording/QueryRecording can look it up later without the
+// client having to remember resourceId/sid.
+type RecordingService struct {
+	db  *gorm.DB
+	cfg *config.Config
+}
+
+func NewRecordingService(db *gorm.DB, cfg *config.Config) *RecordingService {
+	return &RecordingService{db: db, cfg: cfg}
+}
+
+func (s *RecordingService) authHeader() string {
+	creds := s.cfg.AgoraCustomerID + ":" + s.cfg.AgoraCustomerKey
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+}
+
+func (s *RecordingService) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, agoraRecordingBaseURL+"/"+s.cfg.AgoraAppID+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", s.authHeader())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ws: agora cloud recording responded %d for %s", resp.StatusCode, path)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (s *RecordingService) storageConfig() fiberMap {
+	return fiberMap{
+		"vendor":    1, // Amazon S3
+		"region":    s3RegionCodes[s.cfg.S3Region],
+		"bucket":    s.cfg.S3Bucket,
+		"accessKey": s.cfg.S3AccessKeyID,
+		"secretKey": s.cfg.S3SecretAccessKey,
+	}
+}
+
+// StartRecording acquires an Agora Cloud Recording resource for channel,
+// starts mix-mode recording under uid, and persists the session as a
+// CallRecording row so it can be stopped or queried later.
+func (s *RecordingService) StartRecording(ctx context.Context, channel, uid string) (*models.CallRecording, error) {
+	var acquireResp struct {
+		ResourceID string `json:"resourceId"`
+	}
+	if err := s.do(ctx, http.MethodPost, "/cloud_recording/acquire", fiberMap{
+		"cname": channel,
+		"uid":   uid,
+		"clientRequest": fiberMap{
+			"resourceExpiredHour": 24,
+		},
+	}, &acquireResp); err != nil {
+		return nil, fmt.Errorf("acquire: %w", err)
+	}
+
+	var startResp struct {
+		SID string `json:"sid"`
+	}
+	startPath := fmt.Sprintf("/cloud_recording/resourceid/%s/mode/mix/start", acquireResp.ResourceID)
+	if err := s.do(ctx, http.MethodPost, startPath, fiberMap{
+		"cname": channel,
+		"uid":   uid,
+		"clientRequest": fiberMap{
+			"recordingConfig": fiberMap{
+				"channelType": 0,
+				"streamTypes": 2,
+			},
+			"storageConfig": s.storageConfig(),
+		},
+	}, &startResp); err != nil {
+		return nil, fmt.Errorf("start: %w", err)
+	}
+
+	recording := &models.CallRecording{
+		Channel:    channel,
+		UID:        uid,
+		SID:        startResp.SID,
+		ResourceID: acquireResp.ResourceID,
+		StartedAt:  time.Now(),
+	}
+	if err := s.db.Create(recording).Error; err != nil {
+		return nil, err
+	}
+	return recording, nil
+}
+
+// StopRecording stops the most recent unstopped recording for channel
+// and records where Agora will upload the resulting file.
+func (s *RecordingService) StopRecording(ctx context.Context, channel string) (*models.CallRecording, error) {
+	var recording models.CallRecording
+	if err := s.db.Where("channel = ? AND stopped_at IS NULL", channel).Order("started_at desc").First(&recording).Error; err != nil {
+		return nil, err
+	}
+
+	var stopResp struct {
+		ServerResponse struct {
+			FileListMode string      `json:"fileListMode"`
+			FileList     interface{} `json:"fileList"`
+		} `json:"serverResponse"`
+	}
+	stopPath := fmt.Sprintf("/cloud_recording/resourceid/%s/sid/%s/mode/mix/stop", recording.ResourceID, recording.SID)
+	if err := s.do(ctx, http.MethodPost, stopPath, fiberMap{
+		"cname":         recording.Channel,
+		"uid":           recording.UID,
+		"clientRequest": fiberMap{},
+	}, &stopResp); err != nil {
+		return nil, fmt.Errorf("stop: %w", err)
+	}
+
+	now := time.Now()
+	recording.StoppedAt = &now
+	recording.StorageURL = fmt.Sprintf("s3://%s/%s", s.cfg.S3Bucket, recording.Channel)
+	if err := s.db.Save(&recording).Error; err != nil {
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// QueryRecording reports the live status of channel's most recent
+// recording directly from Agora, without touching the CallRecording row.
+func (s *RecordingService) QueryRecording(ctx context.Context, channel string) (map[string]interface{}, error) {
+	var recording models.CallRecording
+	if err := s.db.Where("channel = ?", channel).Order("started_at desc").First(&recording).Error; err != nil {
+		return nil, err
+	}
+
+	var status map[string]interface{}
+	queryPath := fmt.Sprintf("/cloud_recording/resourceid/%s/sid/%s/mode/mix/query", recording.ResourceID, recording.SID)
+	if err := s.do(ctx, http.MethodGet, queryPath, nil, &status); err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return status, nil
+}
+
+// fiberMap is a plain JSON object; declared locally so this file doesn't
+// need to import fiber just for its Map alias.
+type fiberMap = map[string]interface{}
+
+type recordingRequest struct {
+	Channel string `json:"channel"`
+}
+
+// requireInitiator reports whether userID is the channel's recorded
+// initiator (see SetupCall), responding with 403 and returning false
+// otherwise so callers can bail out in one line.
+func requireInitiator(c *fiber.Ctx, channel, userID string) bool {
+	activeCallsMutex.Lock()
+	initiator, ok := activeCalls[channel]
+	activeCallsMutex.Unlock()
+	if !ok || initiator != userID {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{"message": "Only the call initiator can control recording"})
+		return false
+	}
+	return true
+}
+
+// StartRecordingHTTP is the REST equivalent of the "start-recording"
+// agora-signal action, for clients that aren't already on the
+// call-signaling socket when they need to start recording.
+func StartRecordingHTTP(c *fiber.Ctx) error {
+	var req recordingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.Channel == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Missing required field: channel"})
+	}
+	userID := c.Locals("user_id").(string)
+	if !requireInitiator(c, req.Channel, userID) {
+		return nil
+	}
+
+	rec, err := recording.StartRecording(c.Context(), req.Channel, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(rec)
+}
+
+// StopRecordingHTTP is the REST equivalent of the "stop-recording"
+// agora-signal action.
+func StopRecordingHTTP(c *fiber.Ctx) error {
+	var req recordingRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.Channel == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Missing required field: channel"})
+	}
+	if !requireInitiator(c, req.Channel, c.Locals("user_id").(string)) {
+		return nil
+	}
+
+	rec, err := recording.StopRecording(c.Context(), req.Channel)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(rec)
+}
+
+// QueryRecordingHTTP is the REST equivalent of the "query-recording"
+// agora-signal action.
+func QueryRecordingHTTP(c *fiber.Ctx) error {
+	channel := c.Query("channel")
+	if channel == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Missing required query param: channel"})
+	}
+	if !requireInitiator(c, channel, c.Locals("user_id").(string)) {
+		return nil
+	}
+
+	status, err := recording.QueryRecording(c.Context(), channel)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"message": err.Error()})
+	}
+	return c.JSON(status)
+}
+
+// GetTurnCredentialsHTTP issues short-lived TURN credentials for the
+// authenticated caller, for clients that need a relay fallback outside
+// the Agora SDK.
+func GetTurnCredentialsHTTP(c *fiber.Ctx) error {
+	uid := c.Locals("user_id").(string)
+	if wsCfg.TurnServerURL == "" || wsCfg.TurnSharedSecret == "" {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"message": "TURN server not configured"})
+	}
+
+	username, password, urls := GetTurnCredentials(wsCfg, uid, tokenExpiryTime*time.Second)
+	return c.JSON(fiber.Map{
+		"username": username,
+		"password": password,
+		"urls":     urls,
+		"ttl":      tokenExpiryTime,
+	})
+}
+
+// GetTurnCredentials returns time-limited HMAC-SHA1 TURN credentials for
+// cfg.TurnServerURL, following the REST API for TURN Server convention
+// coturn implements: username is "<expiry-unix>:<uid>" and password is
+// base64(HMAC-SHA1(sharedSecret, username)). Clients that can't use the
+// Agora SDK use these to fall back to a relay.
+func GetTurnCredentials(cfg *config.Config, uid string, ttl time.Duration) (username, password, urls string) {
+	expiry := time.Now().Add(ttl).Unix()
+	username = fmt.Sprintf("%d:%s", expiry, uid)
+
+	mac := hmac.New(sha1.New, []byte(cfg.TurnSharedSecret))
+	mac.Write([]byte(username))
+	password = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return username, password, cfg.TurnServerURL
+}
@@ -1,21 +1,27 @@
 package ws
 
 import (
+	"context"
+	"encoding/json"
 	"log"
+	"social-media-app/api/auth"
+	"social-media-app/config"
 	"sync"
 	"time"
 
 	rtctokenbuilder "github.com/AgoraIO/Tools/DynamicKey/AgoraDynamicKey/go/src/rtctokenbuilder2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
 )
 
-// Constants and variables
-const (
-	agoraAppID      = "0ad1df7f5f9241e7bdccc8324d516f27"
-	agoraAppCert    = "de7b71e27cbe4a1fad5783aa0a461576"
-	tokenExpiryTime = 3600 // Token expiry time in seconds
-)
+const tokenExpiryTime = 3600 // Token expiry time in seconds
+
+// wsReadLimit bounds a single inbound frame, so a client can't hold a
+// read pump busy (or OOM it) by streaming an unbounded message.
+const wsReadLimit = 64 * 1024
 
 type CallSignal struct {
 	Type     string      `json:"type"`
@@ -25,24 +31,45 @@ type CallSignal struct {
 	TargetId string      `json:"targetId,omitempty"`
 }
 
-type User struct {
-	UserID string
-	Conn   *websocket.Conn
-}
-
 var (
-	activeUsers = make(map[string]*User)
-	mutex       sync.Mutex
-	activeCalls = make(map[string]string) // channel -> initiator user ID
+	hub       *Hub
+	wsCfg     *config.Config
+	recording *RecordingService
+	calls     *CallManager
+
+	// activeCalls tracks in-progress Agora calls (channel -> initiator
+	// user ID) purely for this node's own cleanup-on-disconnect logic,
+	// so it stays node-local rather than going through the Broker; a
+	// call's signaling messages are still delivered cluster-wide via hub.
+	activeCalls      = make(map[string]string)
+	activeCallsMutex sync.Mutex
 )
 
-func Setup(app fiber.Router) {
-	// WebSocket endpoint
-	app.Get("/", websocket.New(handleWebSocket, websocket.Config{
+// Setup mounts the legacy call-signaling socket at GET /ws and wires it
+// to broker for cluster-wide delivery, so two participants in the same
+// call can be connected to different nodes. It also wires up Agora
+// Cloud Recording control and TURN credential issuance, both of which
+// need cfg/db alongside the signaling socket.
+func Setup(app fiber.Router, broker Broker, cfg *config.Config, db *gorm.DB, redisClient *redis.Client) {
+	hub = NewHub(broker)
+	wsCfg = cfg
+	recording = NewRecordingService(db, cfg)
+	calls = NewCallManager(db, redisClient, broker)
+
+	app.Get("/", auth.WebSocketJWTMiddleware(cfg, redisClient), websocket.New(handleWebSocket, websocket.Config{
 		EnableCompression: true,
 		ReadBufferSize:    1024,
 		WriteBufferSize:   1024,
 	}))
+
+	app.Post("/recording/start", auth.JWTMiddleware(cfg, redisClient), StartRecordingHTTP)
+	app.Post("/recording/stop", auth.JWTMiddleware(cfg, redisClient), StopRecordingHTTP)
+	app.Get("/recording/query", auth.JWTMiddleware(cfg, redisClient), QueryRecordingHTTP)
+	app.Get("/turn-credentials", auth.JWTMiddleware(cfg, redisClient), GetTurnCredentialsHTTP)
+	app.Get("/call/history", auth.JWTMiddleware(cfg, redisClient), CallHistoryHTTP)
+
+	app.Get("/presence/:userId", auth.JWTMiddleware(cfg, redisClient), GetUserPresence)
+	app.Get("/presence", auth.JWTMiddleware(cfg, redisClient), GetActivePresence)
 }
 
 func GetAgoraToken(c *fiber.Ctx) error {
@@ -78,8 +105,8 @@ func GenerateAgoraToken(c *fiber.Ctx, channel, role, uid string) error {
 
 	expireTime := uint32(time.Now().Unix() + tokenExpiryTime)
 	token, err := rtctokenbuilder.BuildTokenWithUserAccount(
-		agoraAppID,
-		agoraAppCert,
+		wsCfg.AgoraAppID,
+		wsCfg.AgoraAppCert,
 		channel,
 		uid,
 		roleValue,
@@ -96,38 +123,84 @@ func GenerateAgoraToken(c *fiber.Ctx, channel, role, uid string) error {
 	log.Printf("Generated token successfully for channel: %s, uid: %s", channel, uid)
 	return c.JSON(fiber.Map{
 		"token":   token,
-		"appId":   agoraAppID,
+		"appId":   wsCfg.AgoraAppID,
 		"channel": channel,
 		"uid":     uid,
 	})
 }
 
+// SetupCall generates Agora tokens for both sides of a bidirectional call
+// and records the caller as the channel's initiator.
+func SetupCall(c *fiber.Ctx) error {
+	var req struct {
+		Channel  string `json:"channel"`
+		CallerID string `json:"callerId"`
+		CalleeID string `json:"calleeId"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"message": "Invalid request"})
+	}
+	if req.Channel == "" || req.CallerID == "" || req.CalleeID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Missing required fields: channel, callerId, calleeId",
+		})
+	}
+
+	expireTime := uint32(time.Now().Unix() + tokenExpiryTime)
+	callerToken, err := rtctokenbuilder.BuildTokenWithUserAccount(
+		wsCfg.AgoraAppID, wsCfg.AgoraAppCert, req.Channel, req.CallerID,
+		rtctokenbuilder.RolePublisher, expireTime, expireTime,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate caller token: " + err.Error()})
+	}
+	calleeToken, err := rtctokenbuilder.BuildTokenWithUserAccount(
+		wsCfg.AgoraAppID, wsCfg.AgoraAppCert, req.Channel, req.CalleeID,
+		rtctokenbuilder.RolePublisher, expireTime, expireTime,
+	)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate callee token: " + err.Error()})
+	}
+
+	activeCallsMutex.Lock()
+	activeCalls[req.Channel] = req.CallerID
+	activeCallsMutex.Unlock()
+
+	return c.JSON(fiber.Map{
+		"appId":       wsCfg.AgoraAppID,
+		"channel":     req.Channel,
+		"callerToken": callerToken,
+		"calleeToken": calleeToken,
+	})
+}
+
 func handleWebSocket(c *websocket.Conn) {
-	c.SetReadDeadline(time.Now().Add(60 * time.Second))
-	go func() {
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-		for range ticker.C {
-			if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Println("Ping error:", err)
-				return
-			}
-			c.SetReadDeadline(time.Now().Add(60 * time.Second))
-		}
-	}()
+	userId, _ := c.Locals("user_id").(string)
+	if userId == "" {
+		c.Close()
+		return
+	}
 
+	c.SetReadLimit(wsReadLimit)
+	c.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.SetPongHandler(func(string) error {
 		c.SetReadDeadline(time.Now().Add(60 * time.Second))
 		return nil
 	})
 
-	var userId string
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connID := uuid.NewString()
+	send, unregister := hub.Register(ctx, userId, connID, c)
+	go runWritePump(hub, c, userId, connID, send)
+	log.Printf("User connected: %s", userId)
+	broadcastActiveUsers(ctx)
 
 	for {
 		var msg struct {
-			Type   string                 `json:"type"`
-			UserId string                 `json:"userId"`
-			Data   map[string]interface{} `json:"data"`
+			Type string                 `json:"type"`
+			Data map[string]interface{} `json:"data"`
 		}
 
 		if err := c.ReadJSON(&msg); err != nil {
@@ -139,21 +212,14 @@ func handleWebSocket(c *websocket.Conn) {
 			break
 		}
 
-		log.Printf("Received message from user %s: type=%s", msg.UserId, msg.Type)
+		log.Printf("Received message from user %s: type=%s", userId, msg.Type)
 
 		switch msg.Type {
 		case "new-user-add":
-			userId = msg.UserId
-			mutex.Lock()
-			if _, exists := activeUsers[userId]; exists {
-				log.Printf("User already connected: %s", userId)
-				mutex.Unlock()
-				continue
-			}
-			activeUsers[userId] = &User{UserID: userId, Conn: c}
-			mutex.Unlock()
-			log.Printf("User connected: %s", userId)
-			broadcastActiveUsers()
+			// The connection is already registered against its
+			// JWT-verified identity above; kept as a no-op so older
+			// clients that still send this handshake message don't break.
+			continue
 
 		case "send-message":
 			receiverId, ok := msg.Data["receiverId"].(string)
@@ -161,8 +227,8 @@ func handleWebSocket(c *websocket.Conn) {
 				log.Println("Invalid receiverId in send-message")
 				continue
 			}
-			log.Printf("Sending message from %s to %s", msg.UserId, receiverId)
-			sendToUser(receiverId, map[string]interface{}{
+			log.Printf("Sending message from %s to %s", userId, receiverId)
+			sendToUser(ctx, receiverId, map[string]interface{}{
 				"type": "receive-message",
 				"data": map[string]interface{}{
 					"chatId":    msg.Data["chatId"],
@@ -179,7 +245,7 @@ func handleWebSocket(c *websocket.Conn) {
 				continue
 			}
 			log.Printf("Sending notification to %s", receiverId)
-			sendToUser(receiverId, map[string]interface{}{
+			sendToUser(ctx, receiverId, map[string]interface{}{
 				"type": "notification",
 				"data": msg.Data,
 			})
@@ -196,7 +262,7 @@ func handleWebSocket(c *websocket.Conn) {
 			}
 			log.Printf("Broadcasting post-created to %d followers", len(followerIds))
 			for _, followerId := range followerIds {
-				sendToUser(followerId, map[string]interface{}{
+				sendToUser(ctx, followerId, map[string]interface{}{
 					"type": "new-post",
 					"data": msg.Data["post"],
 				})
@@ -209,7 +275,7 @@ func handleWebSocket(c *websocket.Conn) {
 				continue
 			}
 			log.Printf("Sending post-reaction to %s", postOwner)
-			sendToUser(postOwner, map[string]interface{}{
+			sendToUser(ctx, postOwner, map[string]interface{}{
 				"type": "post-reaction-update",
 				"data": msg.Data,
 			})
@@ -221,14 +287,14 @@ func handleWebSocket(c *websocket.Conn) {
 				continue
 			}
 			log.Printf("Sending comment-added to %s", postOwner)
-			sendToUser(postOwner, map[string]interface{}{
+			sendToUser(ctx, postOwner, map[string]interface{}{
 				"type": "new-comment",
 				"data": msg.Data["comment"],
 			})
 			parentOwner, ok := msg.Data["parentOwner"].(string)
 			if ok && parentOwner != "" {
 				log.Printf("Sending reply notification to %s", parentOwner)
-				sendToUser(parentOwner, map[string]interface{}{
+				sendToUser(ctx, parentOwner, map[string]interface{}{
 					"type": "new-reply",
 					"data": msg.Data["comment"],
 				})
@@ -241,7 +307,7 @@ func handleWebSocket(c *websocket.Conn) {
 				continue
 			}
 			log.Printf("Sending comment-reaction to %s", commentOwner)
-			sendToUser(commentOwner, map[string]interface{}{
+			sendToUser(ctx, commentOwner, map[string]interface{}{
 				"type": "comment-reaction-update",
 				"data": msg.Data,
 			})
@@ -258,11 +324,59 @@ func handleWebSocket(c *websocket.Conn) {
 			}
 			log.Printf("Broadcasting story-created to %d followers", len(followerIds))
 			for _, followerId := range followerIds {
-				sendToUser(followerId, map[string]interface{}{
+				sendToUser(ctx, followerId, map[string]interface{}{
 					"type": "new-story",
 					"data": msg.Data["story"],
 				})
 			}
+		case "call-invite":
+			channel, _ := msg.Data["channel"].(string)
+			callType, _ := msg.Data["callType"].(string)
+			rawTargetIds, ok := msg.Data["targetIds"].([]interface{})
+			if channel == "" || !ok || len(rawTargetIds) == 0 {
+				log.Println("Invalid call-invite: need channel and targetIds")
+				continue
+			}
+			targetIds := make([]string, 0, len(rawTargetIds))
+			for _, t := range rawTargetIds {
+				if id, ok := t.(string); ok {
+					targetIds = append(targetIds, id)
+				}
+			}
+			if _, err := calls.Invite(userId, channel, callType, targetIds); err != nil {
+				log.Printf("ws: call-invite failed for channel %s: %v", channel, err)
+			}
+
+		case "call-accept":
+			callId, ok := msg.Data["callId"].(string)
+			if !ok || callId == "" {
+				log.Println("Invalid call-accept: missing callId")
+				continue
+			}
+			if err := calls.Accept(callId, userId); err != nil {
+				log.Printf("ws: call-accept failed for call %s: %v", callId, err)
+			}
+
+		case "call-reject":
+			callId, ok := msg.Data["callId"].(string)
+			if !ok || callId == "" {
+				log.Println("Invalid call-reject: missing callId")
+				continue
+			}
+			if err := calls.Reject(callId, userId); err != nil {
+				log.Printf("ws: call-reject failed for call %s: %v", callId, err)
+			}
+
+		case "call-leave":
+			callId, ok := msg.Data["callId"].(string)
+			if !ok || callId == "" {
+				log.Println("Invalid call-leave: missing callId")
+				continue
+			}
+			if err := calls.Leave(callId, userId); err != nil {
+				log.Printf("ws: call-leave failed for call %s: %v", callId, err)
+			}
+
 		case "agora-signal":
 			action, ok := msg.Data["action"].(string)
 			if !ok {
@@ -276,6 +390,12 @@ func handleWebSocket(c *websocket.Conn) {
 				continue
 			}
 
+			if action == "start-recording" || action == "stop-recording" || action == "query-recording" {
+				channel, _ := msg.Data["channel"].(string)
+				handleRecordingAction(ctx, action, channel, userId, targetId)
+				continue
+			}
+
 			// For call requests, generate a token for the receiver too
 			if action == "call-request" {
 				channel, ok := msg.Data["channel"].(string)
@@ -284,47 +404,61 @@ func handleWebSocket(c *websocket.Conn) {
 					token, err := generateTokenForUser(channel, "publisher", targetId)
 					if err == nil {
 						msg.Data["receiverToken"] = token
-						msg.Data["appId"] = agoraAppID
+						msg.Data["appId"] = wsCfg.AgoraAppID
 					}
 				}
 			}
 
 			// Forward the signal to the target user
-			sendToUser(targetId, map[string]interface{}{
+			sendToUser(ctx, targetId, map[string]interface{}{
 				"type":   "agora-signal",
-				"userId": msg.UserId,
+				"userId": userId,
 				"data":   msg.Data,
 			})
 		}
 	}
 
 	// Clean up user on disconnect
-	mutex.Lock()
-	delete(activeUsers, userId)
+	unregister()
+
+	disconnectCtx := context.Background()
+
+	var endedChannels []string
+	activeCallsMutex.Lock()
 	for channel, initiator := range activeCalls {
 		if initiator == userId {
 			delete(activeCalls, channel)
+			endedChannels = append(endedChannels, channel)
 			log.Printf("Removed active call on disconnect: channel %s", channel)
-			// Notify other participant
-			for _, user := range activeUsers {
-				if user.UserID != userId {
-					sendToUser(user.UserID, map[string]interface{}{
-						"type":   "agora-signal",
-						"userId": userId,
-						"data": map[string]interface{}{
-							"action":   "call-ended",
-							"channel":  channel,
-							"targetId": user.UserID,
-						},
-					})
+		}
+	}
+	activeCallsMutex.Unlock()
+
+	if len(endedChannels) > 0 {
+		otherUsers, err := hub.ActiveUsers(disconnectCtx)
+		if err != nil {
+			log.Printf("ws: failed to list active users for call-ended notice: %v", err)
+		}
+		for _, channel := range endedChannels {
+			for _, otherUserId := range otherUsers {
+				if otherUserId == userId {
+					continue
 				}
+				sendToUser(disconnectCtx, otherUserId, map[string]interface{}{
+					"type":   "agora-signal",
+					"userId": userId,
+					"data": map[string]interface{}{
+						"action":   "call-ended",
+						"channel":  channel,
+						"targetId": otherUserId,
+					},
+				})
 			}
 		}
 	}
-	mutex.Unlock()
 
 	log.Printf("User disconnected: %s", userId)
-	broadcastActiveUsers()
+	broadcastActiveUsers(disconnectCtx)
 	c.Close()
 }
 func generateTokenForUser(channel, role, uid string) (string, error) {
@@ -337,8 +471,8 @@ func generateTokenForUser(channel, role, uid string) (string, error) {
 
 	expireTime := uint32(time.Now().Unix() + tokenExpiryTime)
 	return rtctokenbuilder.BuildTokenWithUserAccount(
-		agoraAppID,
-		agoraAppCert,
+		wsCfg.AgoraAppID,
+		wsCfg.AgoraAppCert,
 		channel,
 		uid,
 		roleValue,
@@ -347,49 +481,101 @@ func generateTokenForUser(channel, role, uid string) (string, error) {
 	)
 }
 
-func broadcastActiveUsers() {
-	userIds := []string{}
-	mutex.Lock()
-	for id := range activeUsers {
-		userIds = append(userIds, id)
+// handleRecordingAction drives RecordingService for a start/stop/query
+// request sent over the agora-signal action set, gated to the channel's
+// initiator (as recorded by SetupCall in activeCalls) so only the caller
+// who started the call can control its recording. The result is pushed
+// to both participants as a "recording-status" event.
+func handleRecordingAction(ctx context.Context, action, channel, userId, targetId string) {
+	activeCallsMutex.Lock()
+	initiator, ok := activeCalls[channel]
+	activeCallsMutex.Unlock()
+	if !ok || initiator != userId {
+		log.Printf("ws: rejecting %s for channel %s: %s is not the initiator", action, channel, userId)
+		return
 	}
-	mutex.Unlock()
 
-	log.Printf("Broadcasting active users: %v", userIds)
-	for _, user := range activeUsers {
-		if err := user.Conn.WriteJSON(map[string]interface{}{
-			"type": "get-users",
-			"data": userIds,
-		}); err != nil {
-			log.Printf("Error broadcasting to user %s: %v", user.UserID, err)
-			user.Conn.Close()
-			mutex.Lock()
-			delete(activeUsers, user.UserID)
-			mutex.Unlock()
+	var status fiber.Map
+	switch action {
+	case "start-recording":
+		rec, err := recording.StartRecording(ctx, channel, userId)
+		if err != nil {
+			log.Printf("ws: start-recording failed for channel %s: %v", channel, err)
+			status = fiber.Map{"channel": channel, "state": "failed", "error": err.Error()}
+			break
 		}
+		status = fiber.Map{"channel": channel, "state": "recording", "sid": rec.SID}
+	case "stop-recording":
+		rec, err := recording.StopRecording(ctx, channel)
+		if err != nil {
+			log.Printf("ws: stop-recording failed for channel %s: %v", channel, err)
+			status = fiber.Map{"channel": channel, "state": "failed", "error": err.Error()}
+			break
+		}
+		status = fiber.Map{"channel": channel, "state": "stopped", "storageUrl": rec.StorageURL}
+	case "query-recording":
+		result, err := recording.QueryRecording(ctx, channel)
+		if err != nil {
+			log.Printf("ws: query-recording failed for channel %s: %v", channel, err)
+			status = fiber.Map{"channel": channel, "state": "failed", "error": err.Error()}
+			break
+		}
+		status = fiber.Map{"channel": channel, "state": "queried", "serverResponse": result}
+	}
+
+	for _, recipient := range []string{userId, targetId} {
+		sendToUser(ctx, recipient, map[string]interface{}{
+			"type": "recording-status",
+			"data": status,
+		})
 	}
 }
 
-func sendToUser(userId string, payload interface{}) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if user, ok := activeUsers[userId]; ok {
-		if err := user.Conn.WriteJSON(payload); err != nil {
-			log.Printf("Error sending to user %s: %v", userId, err)
-			user.Conn.Close()
-			delete(activeUsers, userId)
-		} else {
-			log.Printf("Sent message to user %s", userId)
-		}
-	} else {
-		log.Printf("User %s not found in active users", userId)
+// broadcastActiveUsers publishes the cluster-wide online user list on
+// globalTopic, so every connected socket on every node gets the same
+// "get-users" view instead of only the ones local to this process.
+func broadcastActiveUsers(ctx context.Context) {
+	userIds, err := hub.ActiveUsers(ctx)
+	if err != nil {
+		log.Printf("ws: failed to list active users: %v", err)
+		return
+	}
+
+	log.Printf("Broadcasting active users: %v", userIds)
+	payload, err := json.Marshal(map[string]interface{}{
+		"type": "get-users",
+		"data": userIds,
+	})
+	if err != nil {
+		log.Printf("ws: failed to marshal active users: %v", err)
+		return
+	}
+	if err := hub.PublishGlobal(ctx, payload); err != nil {
+		log.Printf("ws: failed to broadcast active users: %v", err)
+	}
+}
+
+func sendToUser(ctx context.Context, userId string, payload map[string]interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("ws: failed to marshal payload for %s: %v", userId, err)
+		return
 	}
+	if err := hub.Publish(ctx, userId, data); err != nil {
+		log.Printf("ws: failed to publish to %s: %v", userId, err)
+	}
+}
+
+// IsOnline reports whether userId has an active connection anywhere in
+// the cluster, i.e. whether SendNotification can actually reach them
+// right now.
+func IsOnline(userId string) bool {
+	return hub.IsOnline(context.Background(), userId)
 }
 
 // Existing Send functions (unchanged)
 func SendNotification(userId string, notification map[string]interface{}) {
-	sendToUser(userId, map[string]interface{}{
+	sendToUser(context.Background(), userId, map[string]interface{}{
 		"type": "notification",
 		"data": notification,
 	})
@@ -397,7 +583,7 @@ func SendNotification(userId string, notification map[string]interface{}) {
 
 func SendPostCreated(followers []string, post map[string]interface{}) {
 	for _, followerId := range followers {
-		sendToUser(followerId, map[string]interface{}{
+		sendToUser(context.Background(), followerId, map[string]interface{}{
 			"type": "new-post",
 			"data": post,
 		})
@@ -405,19 +591,19 @@ func SendPostCreated(followers []string, post map[string]interface{}) {
 }
 
 func SendPostReaction(postOwner string, reactionData map[string]interface{}) {
-	sendToUser(postOwner, map[string]interface{}{
+	sendToUser(context.Background(), postOwner, map[string]interface{}{
 		"type": "post-reaction-update",
 		"data": reactionData,
 	})
 }
 
 func SendCommentAdded(postOwner string, parentOwner string, comment map[string]interface{}) {
-	sendToUser(postOwner, map[string]interface{}{
+	sendToUser(context.Background(), postOwner, map[string]interface{}{
 		"type": "new-comment",
 		"data": comment,
 	})
 	if parentOwner != "" {
-		sendToUser(parentOwner, map[string]interface{}{
+		sendToUser(context.Background(), parentOwner, map[string]interface{}{
 			"type": "new-reply",
 			"data": comment,
 		})
@@ -425,7 +611,7 @@ func SendCommentAdded(postOwner string, parentOwner string, comment map[string]i
 }
 
 func SendCommentReaction(commentOwner string, reactionData map[string]interface{}) {
-	sendToUser(commentOwner, map[string]interface{}{
+	sendToUser(context.Background(), commentOwner, map[string]interface{}{
 		"type": "comment-reaction-update",
 		"data": reactionData,
 	})
@@ -433,9 +619,21 @@ func SendCommentReaction(commentOwner string, reactionData map[string]interface{
 
 func SendStoryCreated(followers []string, story map[string]interface{}) {
 	for _, followerId := range followers {
-		sendToUser(followerId, map[string]interface{}{
+		sendToUser(context.Background(), followerId, map[string]interface{}{
 			"type": "new-story",
 			"data": story,
 		})
 	}
 }
+
+// SendStoryExpired tells followers storyId has aged out, so clients can
+// drop it from whatever story tray they're rendering without waiting on
+// a poll.
+func SendStoryExpired(followers []string, storyId string) {
+	for _, followerId := range followers {
+		sendToUser(context.Background(), followerId, map[string]interface{}{
+			"type": "story_expired",
+			"data": map[string]interface{}{"storyId": storyId},
+		})
+	}
+}
@@ -7,14 +7,54 @@ import (
 )
 
 type Config struct {
-	DatabaseURL   string
-	DirectURL     string
-	SupabaseURL   string
+	DatabaseURL     string
+	DirectURL       string
+	SupabaseURL     string
 	SupabaseAnonKey string
-	JWTSecret     string
-	RedisURL      string
-	Port          string
-	CORSOrigin    string
+	JWTSecret       string
+	RedisURL        string
+	Port            string
+	CORSOrigin      string
+	BaseURL         string
+	SMTPHost        string
+	SMTPPort        string
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPFrom        string
+
+	// Storage backend for uploads: "local", "s3", or "supabase".
+	StorageBackend    string
+	S3Bucket          string
+	S3Region          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3Endpoint        string
+	SupabaseBucket    string
+
+	// Push notification providers (services/push). Left empty, each
+	// provider's Send becomes a no-op, matching SMTPMailer's behavior
+	// when SMTP isn't configured.
+	FCMServerKey string
+	APNsAuthKey  string
+	APNsTopic    string
+	APNsHost     string
+
+	// Real-time event broker backend (api/ws): "redis" (default) or
+	// "nats". Presence is tracked in Redis regardless of backend.
+	BrokerBackend string
+	NatsURL       string
+
+	// Agora credentials for RTC tokens and Cloud Recording (api/ws).
+	AgoraAppID       string
+	AgoraAppCert     string
+	AgoraCustomerID  string
+	AgoraCustomerKey string
+
+	// TURN credentials (api/ws.GetTurnCredentials) are time-limited
+	// HMAC-SHA1 secrets shared with a coturn server, per RFC 5766's
+	// REST API for TURN Server recommendation.
+	TurnServerURL    string
+	TurnSharedSecret string
 }
 
 func LoadConfig() (*Config, error) {
@@ -23,13 +63,43 @@ func LoadConfig() (*Config, error) {
 	}
 
 	return &Config{
-		DatabaseURL:   os.Getenv("DATABASE_URL"),
-		DirectURL:     os.Getenv("DIRECT_URL"),
-		SupabaseURL:   os.Getenv("SUPABASE_URL"),
+		DatabaseURL:     os.Getenv("DATABASE_URL"),
+		DirectURL:       os.Getenv("DIRECT_URL"),
+		SupabaseURL:     os.Getenv("SUPABASE_URL"),
 		SupabaseAnonKey: os.Getenv("SUPABASE_ANON_KEY"),
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		RedisURL:      os.Getenv("REDIS_URL"),
-		Port:          os.Getenv("PORT"),
-		CORSOrigin:    os.Getenv("CORS_ORIGIN"),
+		JWTSecret:       os.Getenv("JWT_SECRET"),
+		RedisURL:        os.Getenv("REDIS_URL"),
+		Port:            os.Getenv("PORT"),
+		CORSOrigin:      os.Getenv("CORS_ORIGIN"),
+		BaseURL:         os.Getenv("BASE_URL"),
+		SMTPHost:        os.Getenv("SMTP_HOST"),
+		SMTPPort:        os.Getenv("SMTP_PORT"),
+		SMTPUsername:    os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:    os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:        os.Getenv("SMTP_FROM"),
+
+		StorageBackend:    os.Getenv("STORAGE_BACKEND"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		SupabaseBucket:    os.Getenv("SUPABASE_BUCKET"),
+
+		FCMServerKey: os.Getenv("FCM_SERVER_KEY"),
+		APNsAuthKey:  os.Getenv("APNS_AUTH_KEY"),
+		APNsTopic:    os.Getenv("APNS_TOPIC"),
+		APNsHost:     os.Getenv("APNS_HOST"),
+
+		BrokerBackend: os.Getenv("BROKER_BACKEND"),
+		NatsURL:       os.Getenv("NATS_URL"),
+
+		AgoraAppID:       os.Getenv("AGORA_APP_ID"),
+		AgoraAppCert:     os.Getenv("AGORA_APP_CERTIFICATE"),
+		AgoraCustomerID:  os.Getenv("AGORA_CUSTOMER_ID"),
+		AgoraCustomerKey: os.Getenv("AGORA_CUSTOMER_KEY"),
+
+		TurnServerURL:    os.Getenv("TURN_SERVER_URL"),
+		TurnSharedSecret: os.Getenv("TURN_SHARED_SECRET"),
 	}, nil
-}
\ No newline at end of file
+}
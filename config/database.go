@@ -2,6 +2,7 @@ package config
 
 import (
 	"social-media-app/api/models"
+	"social-media-app/services"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -11,13 +12,22 @@ func InitDB(cfg *Config) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.New(postgres.Config{
 		DSN:                  cfg.DatabaseURL,
 		PreferSimpleProtocol: true, // disables statement caching
-		
-		
+
 	}), &gorm.Config{})
-	
+
 	if err != nil {
 		return nil, err
 	}
-	db.AutoMigrate(&models.User{}, &models.Post{}, &models.Chat{}, &models.Message{}, &models.Product{})
+	db.AutoMigrate(&models.User{}, &models.Post{}, &models.Chat{}, &models.Message{}, &models.Product{}, &models.Story{}, &models.RemoteUser{},
+		&models.AuthFactor{}, &models.AuthChallenge{}, &models.AuthEvent{}, &models.Relationship{}, &models.Attachment{}, &models.RefreshToken{}, &models.AuditRecord{},
+		&models.WebhookSubscription{}, &models.WebhookDelivery{}, &models.DeviceToken{}, &models.CallRecording{},
+		&models.Call{}, &models.CallParticipant{}, &models.MessageStatus{}, &models.ChatMember{}, &models.StoryView{}, &models.StoryReaction{})
+
+	// One-time, idempotent backfill of the legacy follower/following
+	// arrays into the new Relationship table.
+	if err := services.BackfillFromFollowArrays(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
-}
\ No newline at end of file
+}
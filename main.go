@@ -1,19 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"social-media-app/api/activitypub"
 	"social-media-app/api/auth"
 	"social-media-app/api/chat"
 	"social-media-app/api/comment"
+	"social-media-app/api/ephemeral"
 	"social-media-app/api/friend"
 	"social-media-app/api/message"
 	"social-media-app/api/notification"
+	"social-media-app/api/notification/digest"
 	"social-media-app/api/post"
 	"social-media-app/api/story"
 	"social-media-app/api/upload"
 	"social-media-app/api/user"
+	"social-media-app/api/webhook"
 	"social-media-app/api/ws"
 	"social-media-app/config"
+	"social-media-app/services"
+	"social-media-app/services/push"
+	"syscall"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -35,11 +45,17 @@ func main() {
 		log.Fatal("Failed to connect to Redis:", err)
 	}
 
+	broker, err := ws.NewBroker(cfg, redisClient)
+	if err != nil {
+		log.Fatal("Failed to set up realtime broker:", err)
+	}
+
 	app := fiber.New()
 
 	// WS group
 	wsGroup := app.Group("/ws")
-	ws.Setup(wsGroup)
+	ws.Setup(wsGroup, broker, cfg, db, redisClient)
+	ws.SetupGateway(wsGroup, broker, cfg, redisClient)
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
@@ -49,6 +65,10 @@ func main() {
 
 	app.Static("/images", "./public/images")
 
+	// ActivityPub federation endpoints live at the domain root (webfinger
+	// and actor URIs are not namespaced under /api).
+	apHandler := activitypub.Setup(app, db, redisClient, cfg)
+
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"message": "hello users"})
 	})
@@ -61,16 +81,42 @@ func main() {
 	// New endpoint for setting up bidirectional calls
 	api.Post("/setup-call/", ws.SetupCall)
 
+	pushSvc := push.NewService(db, cfg)
+
 	auth.Setup(api, db, redisClient, cfg)
 	user.Setup(api, db, redisClient)
 	post.Setup(api, db, redisClient)
 	chat.Setup(api, db, redisClient)
 	message.Setup(api, db, redisClient)
-	comment.Setup(api, db, redisClient)
-	friend.Setup(api, db, redisClient)
-	notification.Setup(api, db, redisClient)
+	commentHandler := comment.Setup(api, db, redisClient)
+	apHandler.SetCommentCreator(commentHandler)
+	friend.Setup(api, db, redisClient, pushSvc)
+	notification.Setup(api, db, redisClient, pushSvc)
 	story.Setup(api, db, redisClient)
-	upload.Setup(api)
+	upload.Setup(api, db, redisClient, cfg)
+	webhook.Setup(api, db, redisClient)
+
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	relationships := services.NewRelationshipService(db, redisClient)
+	go ephemeral.NewReaper(db, redisClient, relationships, broker).Start(reaperCtx)
+
+	digestCtx, stopDigest := context.WithCancel(context.Background())
+	go digest.NewBatcher(db, redisClient, digest.NewSMTPMailer(cfg)).Start(digestCtx)
+
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	go webhook.NewDispatcher(db, redisClient).Start(webhookCtx)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-quit
+		log.Println("Shutting down: closing gateway connections")
+		stopReaper()
+		stopDigest()
+		stopWebhooks()
+		ws.Shutdown()
+		app.Shutdown()
+	}()
 
 	log.Fatal(app.Listen(":" + cfg.Port))
 }
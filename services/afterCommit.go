@@ -0,0 +1,26 @@
+package services
+
+// AfterCommit collects side effects — cache invalidation, WebSocket
+// pushes, digest enqueues — that a service method gathers while inside
+// a gorm.DB.Transaction block but must defer until the transaction has
+// actually committed. A write that rolls back should never be followed
+// by an event telling some other part of the system it happened.
+//
+// Usage: declare one above the Transaction call, Add to it from inside
+// the closure, then Run it only after Transaction returns a nil error.
+type AfterCommit struct {
+	fns []func()
+}
+
+// Add queues fn to run once the enclosing transaction commits.
+func (a *AfterCommit) Add(fn func()) {
+	a.fns = append(a.fns, fn)
+}
+
+// Run executes every queued function in the order it was added. Callers
+// must only call this after the transaction has committed successfully.
+func (a *AfterCommit) Run() {
+	for _, fn := range a.fns {
+		fn()
+	}
+}
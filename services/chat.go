@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"social-media-app/api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Chat type and chat-member role values. See models.Chat and
+// models.ChatMember for what each field means.
+const (
+	ChatTypeDirect = "direct"
+	ChatTypeGroup  = "group"
+
+	ChatRoleOwner  = "owner"
+	ChatRoleAdmin  = "admin"
+	ChatRoleMember = "member"
+)
+
+var (
+	ErrChatNotFound      = errors.New("chat not found")
+	ErrNotChatMember     = errors.New("not a member of this chat")
+	ErrInsufficientRole  = errors.New("insufficient role for this action")
+	ErrAlreadyChatMember = errors.New("user is already a member of this chat")
+	ErrBlockedMember     = errors.New("cannot add a user who has a blocked relationship with an existing member")
+	ErrCannotRemoveOwner = errors.New("the chat owner can't be removed; transfer ownership first")
+	ErrInvalidRole       = errors.New("role must be admin or member")
+)
+
+// roleRank orders roles so a caller's role can be compared against a
+// minimum requirement: owner > admin > member.
+func roleRank(role string) int {
+	switch role {
+	case ChatRoleOwner:
+		return 2
+	case ChatRoleAdmin:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ChatService owns group-chat membership and role bookkeeping. It keeps
+// models.Chat.Members (the array the rest of the codebase already
+// queries membership through, e.g. "? = ANY(members)") in sync with the
+// authoritative ChatMember rows.
+type ChatService struct {
+	db            *gorm.DB
+	relationships *RelationshipService
+}
+
+func NewChatService(db *gorm.DB, relationships *RelationshipService) *ChatService {
+	return &ChatService{db, relationships}
+}
+
+// CreateGroup creates a new group chat owned by ownerID, with ownerID
+// and memberIDs as its initial ChatMember rows. It fails if any two of
+// the initial members have a blocked relationship, the same restriction
+// AddMember applies when growing the roster later.
+func (s *ChatService) CreateGroup(ctx context.Context, ownerID, name string, memberIDs []string) (*models.Chat, error) {
+	members := models.UUIDArray{ownerID}
+	for _, id := range memberIDs {
+		if id != ownerID {
+			members = append(members, id)
+		}
+	}
+
+	for i, memberID := range members {
+		for _, otherID := range members[i+1:] {
+			blocked, err := s.relationships.IsBlocked(memberID, otherID)
+			if err != nil {
+				return nil, err
+			}
+			if blocked {
+				return nil, ErrBlockedMember
+			}
+		}
+	}
+
+	chat := models.Chat{Name: name, OwnerID: ownerID, Type: ChatTypeGroup, Members: members}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&chat).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		chatMembers := make([]models.ChatMember, len(members))
+		for i, userID := range members {
+			role := ChatRoleMember
+			if userID == ownerID {
+				role = ChatRoleOwner
+			}
+			chatMembers[i] = models.ChatMember{ChatID: chat.ID, UserID: userID, Role: role, JoinedAt: now}
+		}
+		return tx.Create(&chatMembers).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// Role returns userID's role on chatID, or ErrNotChatMember if they
+// aren't a member.
+func (s *ChatService) Role(chatID, userID string) (string, error) {
+	var member models.ChatMember
+	if err := s.db.Where("chat_id = ? AND user_id = ?", chatID, userID).First(&member).Error; err != nil {
+		return "", ErrNotChatMember
+	}
+	return member.Role, nil
+}
+
+// requireRole returns ErrInsufficientRole unless userID's role on
+// chatID ranks at or above minRole.
+func (s *ChatService) requireRole(chatID, userID, minRole string) error {
+	role, err := s.Role(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if roleRank(role) < roleRank(minRole) {
+		return ErrInsufficientRole
+	}
+	return nil
+}
+
+// Rename renames a group chat. The caller must be at least an admin.
+func (s *ChatService) Rename(chatID, userID, name string) (*models.Chat, error) {
+	if err := s.requireRole(chatID, userID, ChatRoleAdmin); err != nil {
+		return nil, err
+	}
+
+	var chat models.Chat
+	if err := s.db.Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return nil, ErrChatNotFound
+	}
+	chat.Name = name
+	if err := s.db.Save(&chat).Error; err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// AddMember adds newUserID to chatID as a plain member. The caller must
+// be at least an admin, and neither they nor any existing member may
+// have a blocked relationship with newUserID.
+func (s *ChatService) AddMember(chatID, actorID, newUserID string) error {
+	if err := s.requireRole(chatID, actorID, ChatRoleAdmin); err != nil {
+		return err
+	}
+
+	var existing models.ChatMember
+	if err := s.db.Where("chat_id = ? AND user_id = ?", chatID, newUserID).First(&existing).Error; err == nil {
+		return ErrAlreadyChatMember
+	}
+
+	var members []models.ChatMember
+	if err := s.db.Where("chat_id = ?", chatID).Find(&members).Error; err != nil {
+		return err
+	}
+	for _, member := range members {
+		blocked, err := s.relationships.IsBlocked(member.UserID, newUserID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrBlockedMember
+		}
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		member := models.ChatMember{ChatID: chatID, UserID: newUserID, Role: ChatRoleMember, JoinedAt: time.Now()}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Chat{}).Where("id = ?", chatID).
+			Update("members", gorm.Expr("array_append(members, ?::uuid)", newUserID)).Error
+	})
+}
+
+// RemoveMember removes targetUserID from chatID. The caller must be at
+// least an admin; the owner can't be removed this way (see
+// TransferOwnership).
+func (s *ChatService) RemoveMember(chatID, actorID, targetUserID string) error {
+	if err := s.requireRole(chatID, actorID, ChatRoleAdmin); err != nil {
+		return err
+	}
+
+	targetRole, err := s.Role(chatID, targetUserID)
+	if err != nil {
+		return err
+	}
+	if targetRole == ChatRoleOwner {
+		return ErrCannotRemoveOwner
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_id = ? AND user_id = ?", chatID, targetUserID).Delete(&models.ChatMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Chat{}).Where("id = ?", chatID).
+			Update("members", gorm.Expr("array_remove(members, ?::uuid)", targetUserID)).Error
+	})
+}
+
+// Leave removes userID from chatID. The owner must TransferOwnership
+// before leaving.
+func (s *ChatService) Leave(chatID, userID string) error {
+	role, err := s.Role(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if role == ChatRoleOwner {
+		return ErrCannotRemoveOwner
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("chat_id = ? AND user_id = ?", chatID, userID).Delete(&models.ChatMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Chat{}).Where("id = ?", chatID).
+			Update("members", gorm.Expr("array_remove(members, ?::uuid)", userID)).Error
+	})
+}
+
+// SetRole promotes or demotes targetUserID between ChatRoleMember and
+// ChatRoleAdmin. The caller must be the chat owner; the owner role
+// itself only changes via TransferOwnership.
+func (s *ChatService) SetRole(chatID, actorID, targetUserID, role string) error {
+	if role != ChatRoleAdmin && role != ChatRoleMember {
+		return ErrInvalidRole
+	}
+	if err := s.requireRole(chatID, actorID, ChatRoleOwner); err != nil {
+		return err
+	}
+
+	targetRole, err := s.Role(chatID, targetUserID)
+	if err != nil {
+		return err
+	}
+	if targetRole == ChatRoleOwner {
+		return ErrCannotRemoveOwner
+	}
+
+	return s.db.Model(&models.ChatMember{}).Where("chat_id = ? AND user_id = ?", chatID, targetUserID).
+		Update("role", role).Error
+}
+
+// TransferOwnership hands ownership of chatID from actorID, the current
+// owner, to newOwnerID, demoting actorID to admin.
+func (s *ChatService) TransferOwnership(chatID, actorID, newOwnerID string) error {
+	if err := s.requireRole(chatID, actorID, ChatRoleOwner); err != nil {
+		return err
+	}
+	if _, err := s.Role(chatID, newOwnerID); err != nil {
+		return err
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ChatMember{}).Where("chat_id = ? AND user_id = ?", chatID, actorID).
+			Update("role", ChatRoleAdmin).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ChatMember{}).Where("chat_id = ? AND user_id = ?", chatID, newOwnerID).
+			Update("role", ChatRoleOwner).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Chat{}).Where("id = ?", chatID).Update("owner_id", newOwnerID).Error
+	})
+}
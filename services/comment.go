@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"social-media-app/api/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// validCommentReactions mirrors the reaction set the old handler
+// accepted inline.
+var validCommentReactions = map[string]bool{
+	"like": true, "love": true, "haha": true, "wow": true,
+	"sad": true, "angry": true, "care": true,
+}
+
+// CommentService owns the Comment table's writes: creating, editing,
+// deleting, and reacting to a comment, plus the CommentCount bump and
+// notifications that go along with create/react. Each method runs its
+// DB work inside a single gorm.DB.Transaction so a comment row, the
+// post's CommentCount, and any notification rows either all land or
+// all roll back together. Cache invalidation and Redis pub/sub are
+// gathered into an AfterCommit and run once the transaction has
+// actually committed, so nothing downstream ever reacts to a write
+// that got rolled back.
+type CommentService struct {
+	db            *gorm.DB
+	redisClient   *redis.Client
+	posts         *PostService
+	notifications *NotificationService
+}
+
+func NewCommentService(db *gorm.DB, redisClient *redis.Client, enqueueDigest DigestEnqueueFunc) *CommentService {
+	return &CommentService{
+		db:            db,
+		redisClient:   redisClient,
+		posts:         NewPostService(db),
+		notifications: NewNotificationService(db, redisClient, enqueueDigest),
+	}
+}
+
+// CreateComment persists a new comment on postID, bumps the post's
+// CommentCount, and notifies the post owner and (if different) the
+// parent comment's author. remoteActorURI is non-empty only when the
+// comment originates from a federated ActivityPub actor.
+func (s *CommentService) CreateComment(postID, userID, text string, parentID *string, remoteActorURI string) (*models.Comment, error) {
+	var comment models.Comment
+	ac := &AfterCommit{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		post, err := s.posts.Get(tx, postID)
+		if err != nil {
+			return err
+		}
+
+		comment = models.Comment{
+			PostID:         postID,
+			UserID:         userID,
+			Text:           text,
+			ParentID:       parentID,
+			RemoteActorURI: remoteActorURI,
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return WrapAppError(ErrCodeInternal, 500, "Failed to create comment", err)
+		}
+
+		if err := s.posts.ChangeCommentCount(tx, post, 1); err != nil {
+			return err
+		}
+
+		var commenter models.User
+		if err := tx.Where("id = ?", userID).First(&commenter).Error; err == nil {
+			if post.UserID != userID {
+				s.queueCommentNotification(tx, ac, post.UserID, userID, commenter.Username,
+					"comment", commenter.Username+" commented on your post", postID, comment.ID)
+			}
+
+			if parentID != nil {
+				var parentComment models.Comment
+				if err := tx.Where("id = ?", *parentID).First(&parentComment).Error; err == nil {
+					if parentComment.UserID != userID && parentComment.UserID != post.UserID {
+						s.queueCommentNotification(tx, ac, parentComment.UserID, userID, commenter.Username,
+							"comment_reply", commenter.Username+" replied to your comment", postID, comment.ID)
+					}
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commentJSON, _ := json.Marshal(comment)
+	ac.Add(func() {
+		s.redisClient.Set(context.Background(), "comment:"+comment.ID, commentJSON, 3600)
+		s.redisClient.Del(context.Background(), "comments:post:"+postID)
+		s.redisClient.Del(context.Background(), "post:"+postID)
+	})
+	ac.Run()
+
+	return &comment, nil
+}
+
+// queueCommentNotification creates a notification row on tx and queues
+// its publish/digest side effects onto ac, ready to run once the
+// transaction commits.
+func (s *CommentService) queueCommentNotification(tx *gorm.DB, ac *AfterCommit, toUserID, fromUserID, fromUsername, notifType, message, postID, commentID string) {
+	notification := models.Notification{
+		UserID:     toUserID,
+		Type:       notifType,
+		FromUserID: fromUserID,
+		PostID:     &postID,
+		CommentID:  &commentID,
+		Message:    message,
+		Read:       false,
+	}
+	if _, err := s.notifications.Create(tx, notification); err != nil {
+		log.Printf("comment: failed to create %s notification for %s: %v", notifType, toUserID, err)
+		return
+	}
+
+	ac.Add(func() {
+		s.notifications.Publish(notification)
+		if err := s.notifications.EnqueueDigest(toUserID, DigestEvent{
+			Type: notifType, FromUserID: fromUserID, FromUsername: fromUsername,
+			PostID: &postID, CommentID: &commentID, CreatedAt: notification.CreatedAt,
+		}); err != nil {
+			log.Printf("comment: failed to enqueue digest event for %s: %v", toUserID, err)
+		}
+	})
+}
+
+// UpdateComment edits commentID's text. Returns an AppError with
+// ErrCodeNotFound or ErrCodeForbidden if the comment doesn't exist or
+// userID doesn't own it.
+func (s *CommentService) UpdateComment(commentID, userID, text string) (*models.Comment, error) {
+	var comment models.Comment
+	ac := &AfterCommit{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", commentID).First(&comment).Error; err != nil {
+			return NewAppError(ErrCodeNotFound, 404, "Comment not found")
+		}
+		if comment.UserID != userID {
+			return NewAppError(ErrCodeForbidden, 403, "Access denied: You can only edit your own comments")
+		}
+
+		comment.Text = text
+		if err := tx.Save(&comment).Error; err != nil {
+			return WrapAppError(ErrCodeInternal, 500, "Failed to update comment", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	commentJSON, _ := json.Marshal(comment)
+	ac.Add(func() {
+		s.redisClient.Set(context.Background(), "comment:"+commentID, commentJSON, 3600)
+		s.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
+	})
+	ac.Run()
+
+	return &comment, nil
+}
+
+// DeleteComment removes commentID and any direct replies to it, and
+// decrements the parent post's CommentCount. A missing post is
+// tolerated (best-effort) since the comment should still be deletable.
+func (s *CommentService) DeleteComment(commentID, userID string) error {
+	var comment models.Comment
+	ac := &AfterCommit{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", commentID).First(&comment).Error; err != nil {
+			return NewAppError(ErrCodeNotFound, 404, "Comment not found")
+		}
+		if comment.UserID != userID {
+			return NewAppError(ErrCodeForbidden, 403, "Access denied: You can only delete your own comments")
+		}
+
+		if post, err := s.posts.Get(tx, comment.PostID); err == nil {
+			if err := s.posts.ChangeCommentCount(tx, post, -1); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("id = ? OR parent_id = ?", commentID, commentID).Delete(&models.Comment{}).Error; err != nil {
+			return WrapAppError(ErrCodeInternal, 500, "Failed to delete comment", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.Add(func() {
+		s.redisClient.Del(context.Background(), "comment:"+commentID)
+		s.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
+		s.redisClient.Del(context.Background(), "post:"+comment.PostID)
+	})
+	ac.Run()
+
+	return nil
+}
+
+// LikeComment toggles userID's reaction on commentID to reactionType
+// (an empty reactionType clears it) and, if the reaction changed to a
+// non-empty value on someone else's comment, notifies its author. It
+// returns the updated comment and a human-readable status message.
+func (s *CommentService) LikeComment(commentID, userID, reactionType string) (*models.Comment, string, error) {
+	if reactionType != "" && !validCommentReactions[reactionType] {
+		return nil, "", NewAppError(ErrCodeInvalid, 400, "Invalid reaction type")
+	}
+
+	var comment models.Comment
+	ac := &AfterCommit{}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", commentID).First(&comment).Error; err != nil {
+			return NewAppError(ErrCodeNotFound, 404, "Comment not found")
+		}
+
+		if comment.Reactions == nil {
+			comment.Reactions = make(map[string][]string)
+		}
+
+		currentReaction := ""
+		added := false
+		for rType, users := range comment.Reactions {
+			for _, id := range users {
+				if id == userID {
+					currentReaction = rType
+					break
+				}
+			}
+		}
+
+		if currentReaction == reactionType {
+			comment.Reactions[currentReaction] = removeUser(comment.Reactions[currentReaction], userID)
+			if len(comment.Reactions[currentReaction]) == 0 {
+				delete(comment.Reactions, currentReaction)
+			}
+		} else {
+			if currentReaction != "" {
+				comment.Reactions[currentReaction] = removeUser(comment.Reactions[currentReaction], userID)
+				if len(comment.Reactions[currentReaction]) == 0 {
+					delete(comment.Reactions, currentReaction)
+				}
+			}
+			if reactionType != "" {
+				comment.Reactions[reactionType] = append(comment.Reactions[reactionType], userID)
+				added = true
+			}
+		}
+
+		if err := tx.Save(&comment).Error; err != nil {
+			return WrapAppError(ErrCodeInternal, 500, "Failed to save reaction", err)
+		}
+
+		var liker models.User
+		if err := tx.Where("id = ?", userID).First(&liker).Error; err == nil && added && comment.UserID != userID {
+			s.queueCommentNotification(tx, ac, comment.UserID, userID, liker.Username,
+				"comment_"+reactionType, liker.Username+" reacted "+reactionType+" to your comment",
+				comment.PostID, comment.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	commentJSON, _ := json.Marshal(comment)
+	ac.Add(func() {
+		s.redisClient.Set(context.Background(), "comment:"+commentID, commentJSON, 3600)
+		s.redisClient.Del(context.Background(), "comments:post:"+comment.PostID)
+	})
+	ac.Run()
+
+	message := "Comment " + reactionType
+	if reactionType == "" {
+		message = "Reaction removed"
+	}
+	return &comment, message, nil
+}
+
+func removeUser(users []string, userID string) []string {
+	for i, id := range users {
+		if id == userID {
+			return append(users[:i], users[i+1:]...)
+		}
+	}
+	return users
+}
@@ -0,0 +1,47 @@
+package services
+
+// Error codes AppError.Code takes: stable strings a caller can switch
+// on without knowing the numeric status. The api handlers respond with
+// AppError.StatusCode directly rather than mapping from these.
+const (
+	ErrCodeNotFound  = "not_found"
+	ErrCodeForbidden = "forbidden"
+	ErrCodeInvalid   = "invalid_input"
+	ErrCodeInternal  = "internal"
+)
+
+// AppError is a service-layer error carrying enough information for a
+// Fiber handler to respond without inspecting the underlying error:
+// Code is a stable string a handler (or another service) can switch on,
+// StatusCode is the HTTP status that maps to it, and Message is safe to
+// send back to the client. Err, when set, is the underlying cause kept
+// for logging and Unwrap, not for display.
+type AppError struct {
+	Code       string
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// NewAppError builds an AppError with no underlying cause, for
+// validation-style failures that originate in the service itself.
+func NewAppError(code string, statusCode int, message string) *AppError {
+	return &AppError{Code: code, StatusCode: statusCode, Message: message}
+}
+
+// WrapAppError builds an AppError around an underlying error, e.g. one
+// returned by gorm, so the original cause is still available via Unwrap.
+func WrapAppError(code string, statusCode int, message string, err error) *AppError {
+	return &AppError{Code: code, StatusCode: statusCode, Message: message, Err: err}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"social-media-app/api/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// MessageService validates and persists chat messages, then publishes
+// the result over Redis and queues recipient notifications. It exists
+// so both the REST handler and the WebSocket read loop in api/message
+// share one implementation of that logic instead of duplicating it.
+type MessageService struct {
+	db            *gorm.DB
+	redisClient   *redis.Client
+	relationships *RelationshipService
+	notifications *NotificationService
+}
+
+func NewMessageService(db *gorm.DB, redisClient *redis.Client, relationships *RelationshipService, notifications *NotificationService) *MessageService {
+	return &MessageService{db, redisClient, relationships, notifications}
+}
+
+// CreateMessage validates senderID is a member of chatID (and, for
+// direct chats, friends with its other member and not blocked by them)
+// then persists text as a new Message, notifying the chat's other
+// members and publishing it on the chat's Redis channel.
+func (s *MessageService) CreateMessage(chatID, senderID, text string) (*models.Message, error) {
+	var chat models.Chat
+	if err := s.db.Where("id = ?", chatID).First(&chat).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "Chat not found")
+	}
+
+	var sender models.User
+	if err := s.db.Where("id = ?", senderID).First(&sender).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "Sender not found")
+	}
+
+	isMember := false
+	for _, memberID := range chat.Members {
+		if memberID == senderID {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		return nil, NewAppError(ErrCodeForbidden, 403, "Sender is not a member of this chat")
+	}
+
+	others, err := s.authorizeMembers(chat, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	message := models.Message{ChatID: chatID, SenderID: senderID, Text: text}
+	if err := s.db.Create(&message).Error; err != nil {
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to save message", err)
+	}
+
+	for _, recipientID := range others {
+		s.queueMessageNotification(recipientID, sender, message)
+	}
+	s.publishChatEvent(chatID, "new-message", message)
+
+	return &message, nil
+}
+
+// authorizeMembers returns the other members of chat senderID may
+// message, or an AppError if they can't. Every chat, direct or group,
+// blocks the send if senderID has a blocked relationship with any other
+// member; direct chats additionally require the two users to be
+// friends, since a group can contain members who aren't mutual friends.
+func (s *MessageService) authorizeMembers(chat models.Chat, senderID string) ([]string, error) {
+	var others []string
+	for _, memberID := range chat.Members {
+		if memberID != senderID {
+			others = append(others, memberID)
+		}
+	}
+
+	for _, memberID := range others {
+		blocked, err := s.relationships.IsBlocked(senderID, memberID)
+		if err != nil {
+			return nil, WrapAppError(ErrCodeInternal, 500, "Failed to check block status", err)
+		}
+		if blocked {
+			return nil, NewAppError(ErrCodeForbidden, 403, "Cannot message a user you've blocked or been blocked by")
+		}
+	}
+
+	if chat.Type == ChatTypeGroup {
+		return others, nil
+	}
+
+	for _, memberID := range others {
+		isFriend, err := s.relationships.IsFriend(senderID, memberID)
+		if err != nil {
+			return nil, WrapAppError(ErrCodeInternal, 500, "Failed to check friend status", err)
+		}
+		if !isFriend {
+			return nil, NewAppError(ErrCodeForbidden, 403, "Users must be friends to send messages")
+		}
+	}
+	return others, nil
+}
+
+// publishChatEvent wraps data in a {type, chatId, data} envelope and
+// publishes it on the chat's Redis channel, mirroring
+// message.MessageHandler.publishChatEvent so every connection
+// subscribed to it can dispatch on Type the same way regardless of
+// which layer produced the event.
+func (s *MessageService) publishChatEvent(chatID, eventType string, data interface{}) {
+	payload, err := json.Marshal(map[string]interface{}{"type": eventType, "chatId": chatID, "data": data})
+	if err != nil {
+		return
+	}
+	s.redisClient.Publish(context.Background(), "chat:"+chatID, payload)
+}
+
+// queueMessageNotification persists a Notification row for toUserID so a
+// recipient who's offline when the message is published over the chat
+// channel still sees it in their notification backlog once they reconnect.
+func (s *MessageService) queueMessageNotification(toUserID string, sender models.User, message models.Message) {
+	notification := models.Notification{
+		UserID:     toUserID,
+		Type:       "message",
+		FromUserID: sender.ID,
+		Message:    sender.Username + " sent you a message",
+	}
+	if _, err := s.notifications.Create(s.db, notification); err != nil {
+		log.Printf("message: failed to create notification for %s: %v", toUserID, err)
+		return
+	}
+
+	s.notifications.Publish(notification)
+	if err := s.notifications.EnqueueDigest(toUserID, DigestEvent{
+		Type: "message", FromUserID: sender.ID, FromUsername: sender.Username, CreatedAt: notification.CreatedAt,
+	}); err != nil {
+		log.Printf("message: failed to enqueue digest event for %s: %v", toUserID, err)
+	}
+}
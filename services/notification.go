@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// DigestEvent mirrors digest.Event's fields. It's declared here instead
+// of importing the digest package directly, because digest imports
+// config and config imports services (for its migration backfill),
+// so services importing digest would close an import cycle. Callers
+// wire a DigestEnqueueFunc that converts this into a real digest.Event.
+type DigestEvent struct {
+	Type         string
+	FromUserID   string
+	FromUsername string
+	PostID       *string
+	CommentID    *string
+	CreatedAt    time.Time
+}
+
+// DigestEnqueueFunc queues event for userID's email digest batch. The
+// caller that constructs a NotificationService supplies one backed by
+// digest.Enqueue.
+type DigestEnqueueFunc func(ctx context.Context, userID string, event DigestEvent) error
+
+// NotificationService owns writing Notification rows and the realtime
+// fan-out that follows one, so callers like CommentService don't embed
+// the Redis publish/digest-enqueue pair inline at every call site.
+type NotificationService struct {
+	db            *gorm.DB
+	redisClient   *redis.Client
+	enqueueDigest DigestEnqueueFunc
+}
+
+func NewNotificationService(db *gorm.DB, redisClient *redis.Client, enqueueDigest DigestEnqueueFunc) *NotificationService {
+	return &NotificationService{db: db, redisClient: redisClient, enqueueDigest: enqueueDigest}
+}
+
+// Create persists notification on tx, so callers running inside a
+// transaction get rollback-safety for free; pass the service's own db
+// if there's no enclosing transaction.
+func (s *NotificationService) Create(tx *gorm.DB, notification models.Notification) (*models.Notification, error) {
+	if err := tx.Create(&notification).Error; err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// Publish pushes notification to its recipient over the existing Redis
+// pub/sub channel the WebSocket gateway subscribes to. Callers run this
+// from an AfterCommit hook, never inline inside the transaction that
+// created the row.
+func (s *NotificationService) Publish(notification models.Notification) {
+	payload, _ := json.Marshal(notification)
+	s.redisClient.Publish(context.Background(), "notification:"+notification.UserID, payload)
+}
+
+// EnqueueDigest queues notification for userID's email digest batch.
+// Like Publish, this belongs in an AfterCommit hook.
+func (s *NotificationService) EnqueueDigest(userID string, event DigestEvent) error {
+	if s.enqueueDigest == nil {
+		return nil
+	}
+	return s.enqueueDigest(context.Background(), userID, event)
+}
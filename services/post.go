@@ -0,0 +1,37 @@
+package services
+
+import (
+	"social-media-app/api/models"
+
+	"gorm.io/gorm"
+)
+
+// PostService owns the handful of Post mutations that other services
+// need as part of a larger unit of work (e.g. CommentService bumping
+// CommentCount), as opposed to the full CRUD surface api/post owns.
+type PostService struct {
+	db *gorm.DB
+}
+
+func NewPostService(db *gorm.DB) *PostService {
+	return &PostService{db: db}
+}
+
+// Get loads a post by id on tx, returning ErrCodeNotFound if it doesn't exist.
+func (s *PostService) Get(tx *gorm.DB, postID string) (*models.Post, error) {
+	var post models.Post
+	if err := tx.Where("id = ?", postID).First(&post).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "Post not found")
+	}
+	return &post, nil
+}
+
+// ChangeCommentCount adjusts a post's CommentCount by delta on tx, so the
+// caller's own transaction covers this write too.
+func (s *PostService) ChangeCommentCount(tx *gorm.DB, post *models.Post, delta int) error {
+	post.CommentCount += delta
+	if err := tx.Save(post).Error; err != nil {
+		return WrapAppError(ErrCodeInternal, 500, "Failed to update post", err)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"social-media-app/config"
+)
+
+// FCMProvider sends Android pushes through Firebase Cloud Messaging's
+// legacy HTTP API, authenticated with the server key configured in
+// config.Config.
+type FCMProvider struct {
+	cfg *config.Config
+}
+
+func NewFCMProvider(cfg *config.Config) *FCMProvider {
+	return &FCMProvider{cfg: cfg}
+}
+
+type fcmMessage struct {
+	To           string            `json:"to"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Send is a no-op when FCM isn't configured (e.g. local dev), matching
+// digest.SMTPMailer.Send.
+func (p *FCMProvider) Send(ctx context.Context, token string, payload Payload) error {
+	if p.cfg.FCMServerKey == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(fcmMessage{
+		To:           token,
+		Notification: fcmNotification{Title: payload.Title, Body: payload.Body},
+		Data:         payload.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://fcm.googleapis.com/fcm/send", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+p.cfg.FCMServerKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("push: fcm responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// APNsProvider sends iOS pushes through Apple's HTTP/2 API, authenticated
+// with the provider auth key configured in config.Config.
+type APNsProvider struct {
+	cfg *config.Config
+}
+
+func NewAPNsProvider(cfg *config.Config) *APNsProvider {
+	return &APNsProvider{cfg: cfg}
+}
+
+type apnsMessage struct {
+	Aps apnsAlert `json:"aps"`
+}
+
+type apnsAlert struct {
+	Alert apnsAlertBody `json:"alert"`
+}
+
+type apnsAlertBody struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// Send is a no-op when APNs isn't configured (e.g. local dev), matching
+// digest.SMTPMailer.Send.
+func (p *APNsProvider) Send(ctx context.Context, token string, payload Payload) error {
+	if p.cfg.APNsAuthKey == "" || p.cfg.APNsTopic == "" {
+		return nil
+	}
+
+	host := p.cfg.APNsHost
+	if host == "" {
+		host = "https://api.push.apple.com"
+	}
+
+	body, err := json.Marshal(apnsMessage{
+		Aps: apnsAlert{Alert: apnsAlertBody{Title: payload.Title, Body: payload.Body}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/3/device/%s", host, token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("apns-topic", p.cfg.APNsTopic)
+	req.Header.Set("Authorization", "bearer "+p.cfg.APNsAuthKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("push: apns responded %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NoopProvider discards every push. It exists so Service.Send can be
+// exercised in tests without a live FCM/APNs endpoint.
+type NoopProvider struct{}
+
+func (NoopProvider) Send(ctx context.Context, token string, payload Payload) error {
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package push delivers a notification to a user's registered mobile
+// devices through FCM (Android) or APNs (iOS) when they don't have an
+// active WebSocket connection — or always, when the caller sets
+// forcePush. Handlers that already publish an event to Redis for the
+// realtime path call Service.Send right after it, mirroring the
+// notification/digest and webhook fan-out patterns elsewhere in the API.
+package push
+
+import (
+	"context"
+	"log"
+	"social-media-app/api/models"
+	"social-media-app/config"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Platform identifies which provider a device token is routed through.
+type Platform string
+
+const (
+	PlatformIOS     Platform = "ios"
+	PlatformAndroid Platform = "android"
+)
+
+// Payload is the provider-agnostic push notification content.
+type Payload struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Provider abstracts the push transport so Service can be exercised
+// against a no-op stand-in (see NoopProvider) instead of a live
+// FCM/APNs endpoint.
+type Provider interface {
+	Send(ctx context.Context, token string, payload Payload) error
+}
+
+// Service registers device tokens and routes a push to whichever
+// provider a user's device is on.
+type Service struct {
+	db   *gorm.DB
+	fcm  Provider
+	apns Provider
+}
+
+func NewService(db *gorm.DB, cfg *config.Config) *Service {
+	return &Service{db: db, fcm: NewFCMProvider(cfg), apns: NewAPNsProvider(cfg)}
+}
+
+// RegisterToken upserts userID's device token for platform, bumping
+// LastSeenAt so a device that's still actively registering can be told
+// apart from one that's gone stale.
+func (s *Service) RegisterToken(userID, platform, token string) error {
+	now := time.Now()
+
+	var existing models.DeviceToken
+	err := s.db.Where("user_id = ? AND token = ?", userID, token).First(&existing).Error
+	if err == nil {
+		existing.Platform = platform
+		existing.LastSeenAt = now
+		return s.db.Save(&existing).Error
+	}
+
+	return s.db.Create(&models.DeviceToken{
+		UserID: userID, Platform: platform, Token: token, LastSeenAt: now,
+	}).Error
+}
+
+// UnregisterToken removes userID's registration for token, e.g. on
+// logout or uninstall.
+func (s *Service) UnregisterToken(userID, token string) error {
+	return s.db.Where("user_id = ? AND token = ?", userID, token).Delete(&models.DeviceToken{}).Error
+}
+
+// Send pushes payload to every device userID has registered, unless
+// online reports them already reachable over the WebSocket gateway and
+// forcePush is false.
+func (s *Service) Send(ctx context.Context, userID string, payload Payload, online, forcePush bool) error {
+	if online && !forcePush {
+		return nil
+	}
+
+	var tokens []models.DeviceToken
+	if err := s.db.Where("user_id = ?", userID).Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		provider := s.providerFor(Platform(token.Platform))
+		if provider == nil {
+			continue
+		}
+		if err := provider.Send(ctx, token.Token, payload); err != nil {
+			log.Printf("push: failed to deliver %s token for %s: %v", token.Platform, userID, err)
+		}
+	}
+	return nil
+}
+
+func (s *Service) providerFor(platform Platform) Provider {
+	switch platform {
+	case PlatformIOS:
+		return s.apns
+	case PlatformAndroid:
+		return s.fcm
+	default:
+		log.Printf("push: unknown platform %q", platform)
+		return nil
+	}
+}
@@ -0,0 +1,341 @@
+// Package services holds business logic that is shared across API
+// handlers and doesn't belong to any single Fiber route group.
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"social-media-app/api/models"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Relationship status values. See models.Relationship for what each one means.
+const (
+	// RelationshipPending marks the receiving side of a not-yet-answered
+	// friend request; RelationshipWaiting marks the sending side of the
+	// same request. Splitting the request into two rows lets "I sent
+	// this, no answer yet" (Waiting) read differently from "this is
+	// waiting on me" (Pending).
+	RelationshipPending = "Pending"
+	RelationshipWaiting = "Waiting"
+	// RelationshipFriend marks a mutual, accepted friend request. It is
+	// distinct from RelationshipActive, which marks a one-directional
+	// follow edge.
+	RelationshipFriend    = "Friend"
+	RelationshipActive    = "Active"
+	RelationshipBlocked   = "Blocked"
+	RelationshipBlockedBy = "BlockedBy"
+)
+
+var (
+	ErrSelfRelationship  = errors.New("cannot create a relationship with yourself")
+	ErrAlreadyExists     = errors.New("relationship already exists")
+	ErrBlocked           = errors.New("one of the users has blocked the other")
+	ErrRelationshipFound = errors.New("no pending request from this user")
+)
+
+// RelationshipService owns all reads/writes to the Relationship table so
+// the two-row (account -> related, related -> account) bookkeeping stays
+// consistent no matter which handler triggers it.
+type RelationshipService struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+}
+
+func NewRelationshipService(db *gorm.DB, redisClient *redis.Client) *RelationshipService {
+	return &RelationshipService{db, redisClient}
+}
+
+// IsBlocked reports whether either user has blocked the other.
+func (s *RelationshipService) IsBlocked(accountID, relatedID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Relationship{}).
+		Where("(account_id = ? AND related_id = ? AND status IN ?) OR (account_id = ? AND related_id = ? AND status IN ?)",
+			accountID, relatedID, []string{RelationshipBlocked, RelationshipBlockedBy},
+			relatedID, accountID, []string{RelationshipBlocked, RelationshipBlockedBy}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// List returns accountID's relationships, optionally filtered by status.
+func (s *RelationshipService) List(accountID, status string) ([]models.Relationship, error) {
+	q := s.db.Where("account_id = ?", accountID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var rels []models.Relationship
+	err := q.Find(&rels).Error
+	return rels, err
+}
+
+// Followers returns the relationships pointing at accountID, i.e. the
+// accounts that follow it, optionally filtered by status.
+func (s *RelationshipService) Followers(accountID, status string) ([]models.Relationship, error) {
+	q := s.db.Where("related_id = ?", accountID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var rels []models.Relationship
+	err := q.Find(&rels).Error
+	return rels, err
+}
+
+// FollowerCount returns how many accounts have an Active relationship
+// pointing at accountID.
+func (s *RelationshipService) FollowerCount(accountID string) (int64, error) {
+	var count int64
+	err := s.db.Model(&models.Relationship{}).
+		Where("related_id = ? AND status = ?", accountID, RelationshipActive).
+		Count(&count).Error
+	return count, err
+}
+
+// Follow creates a one-directional Active edge from accountID to
+// relatedID. Unlike SendRequest, following needs no acceptance step.
+func (s *RelationshipService) Follow(ctx context.Context, accountID, relatedID string) error {
+	if accountID == relatedID {
+		return ErrSelfRelationship
+	}
+	if blocked, err := s.IsBlocked(accountID, relatedID); err != nil {
+		return err
+	} else if blocked {
+		return ErrBlocked
+	}
+
+	var existing models.Relationship
+	if err := s.db.Where("account_id = ? AND related_id = ?", accountID, relatedID).First(&existing).Error; err == nil {
+		return ErrAlreadyExists
+	}
+
+	rel := models.Relationship{AccountID: accountID, RelatedID: relatedID, Status: RelationshipActive}
+	if err := s.db.Create(&rel).Error; err != nil {
+		return err
+	}
+	s.notify(ctx, relatedID, accountID, "follow", "started following you")
+	return nil
+}
+
+// Unfollow removes accountID's Active edge to relatedID.
+func (s *RelationshipService) Unfollow(accountID, relatedID string) error {
+	return s.db.Where("account_id = ? AND related_id = ? AND status = ?", accountID, relatedID, RelationshipActive).
+		Delete(&models.Relationship{}).Error
+}
+
+// SendRequest creates a friend request from accountID to relatedID as a
+// pair of rows: Waiting on accountID's side (I asked, no answer yet) and
+// Pending on relatedID's side (this is waiting on me to answer).
+func (s *RelationshipService) SendRequest(ctx context.Context, accountID, relatedID string) (*models.Relationship, error) {
+	if accountID == relatedID {
+		return nil, ErrSelfRelationship
+	}
+	if blocked, err := s.IsBlocked(accountID, relatedID); err != nil {
+		return nil, err
+	} else if blocked {
+		return nil, ErrBlocked
+	}
+
+	var existing models.Relationship
+	if err := s.db.Where("account_id = ? AND related_id = ?", accountID, relatedID).First(&existing).Error; err == nil {
+		return nil, ErrAlreadyExists
+	}
+
+	tx := s.db.Begin()
+
+	rel := models.Relationship{AccountID: accountID, RelatedID: relatedID, Status: RelationshipWaiting}
+	if err := tx.Create(&rel).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Create(&models.Relationship{AccountID: relatedID, RelatedID: accountID, Status: RelationshipPending}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	s.notify(ctx, relatedID, accountID, "friend_request", "sent you a friend request")
+	return &rel, nil
+}
+
+// Accept turns a pending request from relatedID into accountID into a
+// mutual Friend relationship by flipping both the receiver's Pending row
+// and the sender's Waiting row.
+func (s *RelationshipService) Accept(ctx context.Context, accountID, relatedID string) error {
+	tx := s.db.Begin()
+
+	var pending models.Relationship
+	if err := tx.Where("account_id = ? AND related_id = ? AND status = ?", accountID, relatedID, RelationshipPending).First(&pending).Error; err != nil {
+		tx.Rollback()
+		return ErrRelationshipFound
+	}
+	pending.Status = RelationshipFriend
+	if err := tx.Save(&pending).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var waiting models.Relationship
+	if err := tx.Where("account_id = ? AND related_id = ? AND status = ?", relatedID, accountID, RelationshipWaiting).First(&waiting).Error; err != nil {
+		tx.Rollback()
+		return ErrRelationshipFound
+	}
+	waiting.Status = RelationshipFriend
+	if err := tx.Save(&waiting).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	s.notify(ctx, relatedID, accountID, "friend_request_accepted", "accepted your friend request")
+	return nil
+}
+
+// Decline removes both rows of a pending request from relatedID to
+// accountID: accountID's Pending row and relatedID's Waiting row.
+func (s *RelationshipService) Decline(accountID, relatedID string) error {
+	tx := s.db.Begin()
+
+	result := tx.Where("account_id = ? AND related_id = ? AND status = ?", accountID, relatedID, RelationshipPending).
+		Delete(&models.Relationship{})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrRelationshipFound
+	}
+
+	if err := tx.Where("account_id = ? AND related_id = ? AND status = ?", relatedID, accountID, RelationshipWaiting).
+		Delete(&models.Relationship{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// Unfriend removes the Friend edges in both directions.
+func (s *RelationshipService) Unfriend(accountID, relatedID string) error {
+	tx := s.db.Begin()
+	if err := tx.Where("account_id = ? AND related_id = ? AND status = ?", accountID, relatedID, RelationshipFriend).
+		Delete(&models.Relationship{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Where("account_id = ? AND related_id = ? AND status = ?", relatedID, accountID, RelationshipFriend).
+		Delete(&models.Relationship{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// IsFriend reports whether accountID and relatedID have accepted each
+// other's friend request.
+func (s *RelationshipService) IsFriend(accountID, relatedID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Relationship{}).
+		Where("account_id = ? AND related_id = ? AND status = ?", accountID, relatedID, RelationshipFriend).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Block replaces any existing edges between the two users with a
+// Blocked/BlockedBy pair so neither side's posts or chats can surface to
+// the other.
+func (s *RelationshipService) Block(accountID, relatedID string) error {
+	if accountID == relatedID {
+		return ErrSelfRelationship
+	}
+
+	tx := s.db.Begin()
+	if err := tx.Where("(account_id = ? AND related_id = ?) OR (account_id = ? AND related_id = ?)",
+		accountID, relatedID, relatedID, accountID).Delete(&models.Relationship{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&models.Relationship{AccountID: accountID, RelatedID: relatedID, Status: RelationshipBlocked}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Create(&models.Relationship{AccountID: relatedID, RelatedID: accountID, Status: RelationshipBlockedBy}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// Mute sets accountID's own Muted flag on its relationship row pointing
+// at relatedID, so a muted Friend (or any other status) stops showing
+// up in accountID's realtime chat delivery without touching the
+// relationship itself. It only ever touches accountID's side: muting is
+// not symmetric the way Block is.
+func (s *RelationshipService) Mute(accountID, relatedID string, muted bool) error {
+	var rel models.Relationship
+	if err := s.db.Where("account_id = ? AND related_id = ?", accountID, relatedID).First(&rel).Error; err != nil {
+		return ErrRelationshipFound
+	}
+	rel.Muted = muted
+	return s.db.Save(&rel).Error
+}
+
+// IsMuted reports whether accountID has muted relatedID.
+func (s *RelationshipService) IsMuted(accountID, relatedID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.Relationship{}).
+		Where("account_id = ? AND related_id = ? AND muted = ?", accountID, relatedID, true).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// notify records a Notification row and publishes it to the recipient's
+// WebSocket channel, mirroring the pattern used across the API handlers.
+func (s *RelationshipService) notify(ctx context.Context, userID, fromUserID, notifType, message string) {
+	var sender models.User
+	if err := s.db.Where("id = ?", fromUserID).First(&sender).Error; err != nil {
+		return
+	}
+
+	notification := models.Notification{
+		UserID:     userID,
+		Type:       notifType,
+		FromUserID: fromUserID,
+		Message:    sender.Username + " " + message,
+	}
+	if err := s.db.Create(&notification).Error; err != nil {
+		return
+	}
+
+	notificationJSON, _ := json.Marshal(notification)
+	s.redisClient.Publish(ctx, "notification:"+userID, notificationJSON)
+}
+
+// BackfillFromFollowArrays migrates the legacy User.Followers/Following
+// string arrays into Active Relationship rows. It is idempotent: rows
+// that already exist for a given (account, related) pair are skipped, so
+// it is safe to run on every startup alongside AutoMigrate.
+func BackfillFromFollowArrays(db *gorm.DB) error {
+	var users []models.User
+	if err := db.Find(&users).Error; err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		for _, followingID := range u.Following {
+			var existing models.Relationship
+			err := db.Where("account_id = ? AND related_id = ?", u.ID, followingID).First(&existing).Error
+			if err == nil {
+				continue
+			}
+			db.Create(&models.Relationship{AccountID: u.ID, RelatedID: followingID, Status: RelationshipActive})
+		}
+	}
+	return nil
+}
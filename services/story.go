@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"social-media-app/api/models"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// StoryService persists stories and fans them out to followers, both in
+// real time over WebSocket and as Notification rows for anyone offline
+// at the time.
+type StoryService struct {
+	db            *gorm.DB
+	redisClient   *redis.Client
+	notifications *NotificationService
+}
+
+func NewStoryService(db *gorm.DB, redisClient *redis.Client, notifications *NotificationService) *StoryService {
+	return &StoryService{db, redisClient, notifications}
+}
+
+// CreateStory persists a Story for userID, caches it in Redis for 24
+// hours, and queues a Notification for each follower so whoever's
+// offline still sees it on reconnect. It returns the story along with
+// userID's followers, since pushing the story to them in real time
+// over WebSocket is the caller's responsibility (services can't import
+// api/ws without creating an import cycle through config).
+func (s *StoryService) CreateStory(userID, text, image, color string) (*models.Story, []string, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, nil, NewAppError(ErrCodeNotFound, 404, "User not found")
+	}
+
+	story := models.Story{
+		UserID: userID,
+		Text:   text,
+		Image:  image,
+		Color:  color,
+	}
+	if err := s.db.Create(&story).Error; err != nil {
+		return nil, nil, WrapAppError(ErrCodeInternal, 500, "Failed to create story", err)
+	}
+
+	storyJSON, _ := json.Marshal(story)
+	s.redisClient.Set(context.Background(), "story:"+story.ID, storyJSON, 24*time.Hour)
+
+	followers := user.Followers
+	s.queueStoryNotifications(user, followers, story)
+
+	return &story, followers, nil
+}
+
+// queueStoryNotifications persists a Notification row for each of
+// user's followers, so one who's offline when the story is pushed over
+// WebSocket still sees it in their notification backlog on reconnect.
+func (s *StoryService) queueStoryNotifications(user models.User, followers []string, story models.Story) {
+	if len(followers) == 0 {
+		return
+	}
+
+	notifications := make([]models.Notification, len(followers))
+	for i, followerID := range followers {
+		notifications[i] = models.Notification{
+			UserID:     followerID,
+			Type:       "story",
+			FromUserID: user.ID,
+			Message:    user.Username + " added a new story",
+		}
+	}
+	if err := s.db.Create(&notifications).Error; err != nil {
+		log.Printf("story: failed to create notifications for story %s: %v", story.ID, err)
+		return
+	}
+
+	for _, notification := range notifications {
+		s.notifications.Publish(notification)
+		if err := s.notifications.EnqueueDigest(notification.UserID, DigestEvent{
+			Type: "story", FromUserID: user.ID, FromUsername: user.Username, CreatedAt: notification.CreatedAt,
+		}); err != nil {
+			log.Printf("story: failed to enqueue digest event for %s: %v", notification.UserID, err)
+		}
+	}
+}
+
+// StoryWithStats is a Story annotated with who's viewed it and how many
+// of each reaction it's gotten, so GetVisibleStories' caller can render
+// seen-state and reaction counts without a separate round trip per story.
+type StoryWithStats struct {
+	models.Story
+	ViewerIDs      []string       `json:"viewerIds"`
+	ReactionCounts map[string]int `json:"reactionCounts"`
+}
+
+// GetVisibleStories returns stories from the last 24 hours posted by
+// userID or anyone they follow, along with each one's viewers and
+// reaction counts.
+func (s *StoryService) GetVisibleStories(userID string) ([]StoryWithStats, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "User not found")
+	}
+
+	followingIDs := []string(user.Following)
+	followingIDs = append(followingIDs, userID)
+
+	var stories []models.Story
+	since := time.Now().Add(-24 * time.Hour)
+	if err := s.db.Where("user_id IN ? AND created_at > ?", followingIDs, since).Find(&stories).Error; err != nil {
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to fetch stories", err)
+	}
+	if len(stories) == 0 {
+		return []StoryWithStats{}, nil
+	}
+
+	storyIDs := make([]string, len(stories))
+	for i, story := range stories {
+		storyIDs[i] = story.ID
+	}
+
+	var views []models.StoryView
+	if err := s.db.Where("story_id IN ?", storyIDs).Find(&views).Error; err != nil {
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to fetch story views", err)
+	}
+	viewerIDsByStory := make(map[string][]string)
+	for _, view := range views {
+		viewerIDsByStory[view.StoryID] = append(viewerIDsByStory[view.StoryID], view.ViewerID)
+	}
+
+	var reactionCounts []struct {
+		StoryID string
+		Emoji   string
+		Count   int
+	}
+	if err := s.db.Model(&models.StoryReaction{}).
+		Select("story_id, emoji, count(*) as count").
+		Where("story_id IN ?", storyIDs).
+		Group("story_id, emoji").
+		Scan(&reactionCounts).Error; err != nil {
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to fetch story reactions", err)
+	}
+	reactionCountsByStory := make(map[string]map[string]int)
+	for _, row := range reactionCounts {
+		if reactionCountsByStory[row.StoryID] == nil {
+			reactionCountsByStory[row.StoryID] = make(map[string]int)
+		}
+		reactionCountsByStory[row.StoryID][row.Emoji] = row.Count
+	}
+
+	result := make([]StoryWithStats, len(stories))
+	for i, story := range stories {
+		result[i] = StoryWithStats{
+			Story:          story,
+			ViewerIDs:      viewerIDsByStory[story.ID],
+			ReactionCounts: reactionCountsByStory[story.ID],
+		}
+	}
+	return result, nil
+}
+
+// ViewStory records that viewerID has seen storyID, if it hasn't
+// already been recorded.
+func (s *StoryService) ViewStory(storyID, viewerID string) error {
+	var story models.Story
+	if err := s.db.Where("id = ?", storyID).First(&story).Error; err != nil {
+		return NewAppError(ErrCodeNotFound, 404, "Story not found")
+	}
+
+	var existing models.StoryView
+	err := s.db.Where("story_id = ? AND viewer_id = ?", storyID, viewerID).First(&existing).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		view := models.StoryView{StoryID: storyID, ViewerID: viewerID, ViewedAt: time.Now()}
+		if err := s.db.Create(&view).Error; err != nil {
+			return WrapAppError(ErrCodeInternal, 500, "Failed to record story view", err)
+		}
+	case err != nil:
+		return WrapAppError(ErrCodeInternal, 500, "Failed to record story view", err)
+	}
+	return nil
+}
+
+// GetViewers returns storyID's viewers, restricted to its owner so
+// other followers can't see who else has watched it.
+func (s *StoryService) GetViewers(storyID, requesterID string) ([]models.StoryView, error) {
+	var story models.Story
+	if err := s.db.Where("id = ?", storyID).First(&story).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "Story not found")
+	}
+	if story.UserID != requesterID {
+		return nil, NewAppError(ErrCodeForbidden, 403, "Only the story's owner can view its viewers")
+	}
+
+	var views []models.StoryView
+	if err := s.db.Where("story_id = ?", storyID).Find(&views).Error; err != nil {
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to fetch story viewers", err)
+	}
+	return views, nil
+}
+
+// ReactToStory records userID's emoji reaction to storyID, replacing
+// any reaction they already left on it.
+func (s *StoryService) ReactToStory(storyID, userID, emoji string) (*models.StoryReaction, error) {
+	var story models.Story
+	if err := s.db.Where("id = ?", storyID).First(&story).Error; err != nil {
+		return nil, NewAppError(ErrCodeNotFound, 404, "Story not found")
+	}
+
+	var reaction models.StoryReaction
+	err := s.db.Where("story_id = ? AND user_id = ?", storyID, userID).First(&reaction).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		reaction = models.StoryReaction{StoryID: storyID, UserID: userID, Emoji: emoji}
+		if err := s.db.Create(&reaction).Error; err != nil {
+			return nil, WrapAppError(ErrCodeInternal, 500, "Failed to save story reaction", err)
+		}
+	case err != nil:
+		return nil, WrapAppError(ErrCodeInternal, 500, "Failed to save story reaction", err)
+	default:
+		reaction.Emoji = emoji
+		if err := s.db.Save(&reaction).Error; err != nil {
+			return nil, WrapAppError(ErrCodeInternal, 500, "Failed to save story reaction", err)
+		}
+	}
+	return &reaction, nil
+}
+
+// ExpiredStory is one Story ExpireStories removed, along with its
+// owner's followers at the time so the caller can notify them without
+// a second lookup after the row is already gone.
+type ExpiredStory struct {
+	StoryID   string
+	Followers []string
+}
+
+// ExpireStories deletes every Story older than 24 hours along with its
+// Redis cache entry, views, and reactions, returning the ones it
+// removed so the caller can notify their followers. It's meant to be
+// called periodically by a background worker (see story.Setup).
+func (s *StoryService) ExpireStories(ctx context.Context) ([]ExpiredStory, error) {
+	var expired []models.Story
+	cutoff := time.Now().Add(-24 * time.Hour)
+	if err := s.db.Where("created_at <= ?", cutoff).Find(&expired).Error; err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(expired))
+	ownerIDs := make([]string, len(expired))
+	for i, story := range expired {
+		ids[i] = story.ID
+		ownerIDs[i] = story.UserID
+	}
+
+	var owners []models.User
+	if err := s.db.Where("id IN ?", ownerIDs).Find(&owners).Error; err != nil {
+		return nil, err
+	}
+	followersByUser := make(map[string][]string, len(owners))
+	for _, owner := range owners {
+		followersByUser[owner.ID] = owner.Followers
+	}
+
+	if err := s.db.Where("story_id IN ?", ids).Delete(&models.StoryView{}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("story_id IN ?", ids).Delete(&models.StoryReaction{}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("id IN ?", ids).Delete(&models.Story{}).Error; err != nil {
+		return nil, err
+	}
+
+	result := make([]ExpiredStory, len(expired))
+	for i, story := range expired {
+		s.redisClient.Del(ctx, "story:"+story.ID)
+		result[i] = ExpiredStory{StoryID: story.ID, Followers: followersByUser[story.UserID]}
+	}
+	return result, nil
+}